@@ -7,7 +7,6 @@ import (
 	"path/filepath"
 	"strings"
 
-	"rules-cli/internal/auth"
 	"rules-cli/internal/formats"
 	"rules-cli/internal/registry"
 	"rules-cli/internal/ruleset"
@@ -256,10 +255,8 @@ func runAddCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("rule '%s' already exists with version %s", identifier.FullName, version)
 	}
 
-	// Create registry client
-	authConfig := auth.LoadAuthConfig()
-	client := registry.NewClient(cfg.RegistryURL)
-	client.SetAuthToken(authConfig.AccessToken)
+	// Create a client for the registry that serves this owner
+	client := newRegistryClient(identifier.OwnerSlug)
 
 	// Download rule and get the actual version
 	actualVersion, err := downloadRule(client, identifier, rulesDir)
@@ -274,6 +271,7 @@ func runAddCommand(cmd *cobra.Command, args []string) error {
 	}
 
 	color.Green("Rule '%s' (version %s) added successfully", identifier.FullName, actualVersion)
+	renderConfiguredTargets()
 
 	// Print format suggestion at the very end if applicable
 	if formatSuggestion != "" {
@@ -285,4 +283,5 @@ func runAddCommand(cmd *cobra.Command, args []string) error {
 
 func init() {
 	rootCmd.AddCommand(addCmd)
+	addNoRenderFlag(addCmd)
 }
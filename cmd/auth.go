@@ -0,0 +1,253 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"rules-cli/internal/auth"
+	"rules-cli/internal/registry"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// authCmd groups commands for managing saved credentials
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage saved credentials and profiles",
+	Long: `Commands for managing the credentials saved by 'rules login'.
+
+Credentials are kept in named profiles, each holding a login for any number
+of registries, so you can switch between e.g. a personal and an employer
+account. Log in to a new profile with 'rules login --profile <name>'.
+
+The profile in use is, in order of precedence: the --profile flag, the
+RULES_PROFILE environment variable, 'profile' in the project config, 'profile'
+in the user config, and finally the profile chosen with 'rules auth switch'.`,
+}
+
+var (
+	authStatusRegistry string
+	authStatusJSON     bool
+)
+
+// authStatus is the output of 'rules auth status'
+type authStatus struct {
+	Profile       string `json:"profile"`
+	Registry      string `json:"registry"`
+	Authenticated bool   `json:"authenticated"`
+	// Source is where the credentials come from: "environment" for
+	// CONTINUE_API_KEY or "profile" for saved credentials
+	Source    string             `json:"source,omitempty"`
+	UserID    string             `json:"userId,omitempty"`
+	Email     string             `json:"email,omitempty"`
+	Username  string             `json:"username,omitempty"`
+	Orgs      []registry.OrgInfo `json:"orgs,omitempty"`
+	ExpiresAt string             `json:"expiresAt,omitempty"`
+	// Verified is whether the registry confirmed the credentials
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+// authStatusCmd represents the auth status command
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which account the CLI is authenticated as",
+	Long: `Shows the profile in use and the account its credentials belong to,
+checking them against the registry. Exits with a non-zero status when not
+authenticated.
+
+Use --json in pipelines to assert which identity a job runs as.`,
+	Example: `  rules auth status
+  rules auth status --registry @acme
+  rules auth status --json | jq -r .username`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		registryURL, err := cfg.ResolveRegistry(authStatusRegistry)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		status := getAuthStatus(registryURL)
+
+		if authStatusJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(status); err != nil {
+				return fmt.Errorf("failed to encode status: %w", err)
+			}
+		} else {
+			printAuthStatus(status)
+		}
+
+		if !status.Authenticated {
+			return fmt.Errorf("not authenticated with %s", registryURL)
+		}
+		return nil
+	},
+}
+
+// getAuthStatus looks up the credentials for registryURL in the active
+// profile and the account they belong to
+func getAuthStatus(registryURL string) authStatus {
+	status := authStatus{Profile: auth.ActiveProfile(), Registry: registryURL}
+
+	authConfig := auth.LoadAuthConfigForRegistry(registryURL)
+	if authConfig.AccessToken == "" {
+		return status
+	}
+
+	status.Authenticated = true
+	status.Source = "profile"
+	if cfg.IsUserRegistry(registryURL) && os.Getenv("CONTINUE_API_KEY") != "" {
+		status.Source = "environment"
+	}
+	status.UserID = authConfig.UserID
+	status.Email = authConfig.UserEmail
+
+	client := newRegistryClientForURL(registryURL)
+	user, err := client.GetCurrentUser()
+	switch {
+	case errors.Is(err, registry.ErrUserInfoUnsupported):
+		// The registry can't confirm who the credentials belong to
+	case errors.Is(err, registry.ErrCredentialsRejected):
+		status.Authenticated = false
+		status.Error = err.Error()
+	case err != nil:
+		status.Error = err.Error()
+	default:
+		status.Verified = true
+		status.UserID = user.ID
+		status.Email = user.Email
+		status.Username = user.Username
+		status.Orgs = user.Orgs
+	}
+
+	// Reload, as the session may have been refreshed while checking it
+	if expiresAt := auth.LoadAuthConfigForRegistry(registryURL).ExpiresAt; expiresAt > 0 && status.Source == "profile" {
+		status.ExpiresAt = time.UnixMilli(expiresAt).UTC().Format(time.RFC3339)
+	}
+
+	return status
+}
+
+// printAuthStatus prints an auth status for people
+func printAuthStatus(status authStatus) {
+	fmt.Printf("Profile: %s\n", status.Profile)
+	fmt.Printf("Registry: %s\n", status.Registry)
+
+	if !status.Authenticated {
+		if status.Error != "" {
+			color.Red("Not authenticated: %s", status.Error)
+		} else {
+			color.Yellow("Not authenticated. Use 'rules login' to authenticate.")
+		}
+		return
+	}
+
+	user := &registry.UserInfo{ID: status.UserID, Email: status.Email, Username: status.Username}
+	if identity := describeUser(user); identity != "" {
+		color.Green("Authenticated as %s", identity)
+	} else {
+		color.Green("Authenticated")
+	}
+
+	if status.Source == "environment" {
+		fmt.Println("Credentials: environment variable (CONTINUE_API_KEY)")
+	} else {
+		fmt.Println("Credentials: saved in profile")
+	}
+	if status.ExpiresAt != "" {
+		fmt.Printf("Token expires: %s\n", status.ExpiresAt)
+	}
+
+	if status.Error != "" {
+		color.Yellow("Could not check credentials with the registry: %s", status.Error)
+	} else if !status.Verified {
+		color.Yellow("%s can't confirm which account the credentials belong to", status.Registry)
+	}
+
+	user.Orgs = status.Orgs
+	if owners := user.PublishableOwners(); status.Verified && len(owners) > 0 {
+		fmt.Printf("Can publish as: %s\n", strings.Join(owners, ", "))
+	}
+}
+
+// authListCmd represents the auth list command
+var authListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved credential profiles",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profiles, err := auth.ListProfiles()
+		if err != nil {
+			return err
+		}
+
+		if len(profiles) == 0 {
+			color.Yellow("No saved credentials. Use 'rules login' to log in.")
+			return nil
+		}
+
+		active := auth.ActiveProfile()
+		color.Cyan("Profiles:")
+		for _, p := range profiles {
+			marker := " "
+			if p.Name == active {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, p.Name)
+
+			registries := make([]string, 0, len(p.Registries))
+			for registryURL := range p.Registries {
+				registries = append(registries, registryURL)
+			}
+			sort.Strings(registries)
+			for _, registryURL := range registries {
+				if email := p.Registries[registryURL].UserEmail; email != "" {
+					fmt.Printf("    %s (%s)\n", registryURL, email)
+				} else {
+					fmt.Printf("    %s\n", registryURL)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// authSwitchCmd represents the auth switch command
+var authSwitchCmd = &cobra.Command{
+	Use:   "switch <profile>",
+	Short: "Switch the active credential profile",
+	Long: `Makes the given profile the one used by default. A profile selected with
+--profile, RULES_PROFILE or the config still takes precedence.`,
+	Example: `  rules auth switch work`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if err := auth.SwitchProfile(name); err != nil {
+			return err
+		}
+
+		color.Green("Switched to profile %s", name)
+		if cfg.Profile != "" && cfg.Profile != name {
+			color.Yellow("Note: profile %s is selected by --profile, RULES_PROFILE or the config and takes precedence here", cfg.Profile)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd)
+	authCmd.AddCommand(authListCmd)
+	authCmd.AddCommand(authSwitchCmd)
+	authCmd.AddCommand(authStatusCmd)
+	authStatusCmd.Flags().StringVar(&authStatusRegistry, "registry", "", "Registry to show the status for, as a scope (e.g. @acme) or URL")
+	authStatusCmd.Flags().BoolVar(&authStatusJSON, "json", false, "Print the status as JSON")
+}
@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"rules-cli/internal/config"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configGlobal     bool
+	configProject    bool
+	configShowSource bool
+)
+
+// configCmd groups commands for reading and writing settings
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and write settings",
+	Long: `Commands for reading and writing the CLI's settings.
+
+Settings are read from, in order of precedence:
+  1. Command line flags (e.g. --format, --profile)
+  2. RULES_* environment variables (e.g. RULES_REGISTRY_URL)
+  3. The project config file, found by looking in the current directory and
+     its parents up to the repository root for .rules/config.yaml, a "config"
+     block in rules.json, or rules-cli.yaml
+  4. The global config file (~/.rules-cli/rules-cli.yaml, or --config)
+  5. Built-in defaults
+
+Use --global or --project to read or write one file only. 'set' and 'unset'
+change the global file unless --project is given.`,
+}
+
+// configGetCmd represents the config get command
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a setting",
+	Example: `  rules config get registry_url
+  rules config get registry_url --show-source
+  rules config get registries.@acme --project`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		if _, _, err := config.LookupSetting(key); err != nil {
+			return err
+		}
+
+		if configGlobal || configProject {
+			file, err := loadScopedConfigFile()
+			if err != nil {
+				return err
+			}
+			value, ok := file.Get(key)
+			if !ok {
+				return fmt.Errorf("%s is not set in %s", key, file.Path)
+			}
+			fmt.Println(config.FormatValue(value) + ignoredSuffix(key, value))
+			return nil
+		}
+
+		resolved, err := config.Resolve(key)
+		if err != nil {
+			return err
+		}
+		if len(resolved) == 0 {
+			return fmt.Errorf("%s is not set", key)
+		}
+
+		// A single value is printed on its own so scripts can use it, map
+		// entries as key = value
+		if len(resolved) == 1 && resolved[0].Key == key {
+			if configShowSource {
+				fmt.Printf("%s (%s)\n", config.FormatValue(resolved[0].Value), describeSources(resolved[0]))
+			} else {
+				fmt.Println(config.FormatValue(resolved[0].Value))
+			}
+			return nil
+		}
+		for _, setting := range resolved {
+			printResolvedSetting(setting, configShowSource)
+		}
+		return nil
+	},
+}
+
+// configSetCmd represents the config set command
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>...",
+	Short: "Change a setting",
+	Long: `Changes a setting in the global config file, or the project config file
+with --project. List settings such as trusted_keys take one or more values,
+which replace the current list.`,
+	Example: `  rules config set default_format cursor
+  rules config set registries.@acme https://rules.acme.dev
+  rules config set signature_policy require --project
+  rules config set targets cursor claude --project`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		setting, name, err := config.LookupSetting(key)
+		if err != nil {
+			return err
+		}
+		if setting.UserOnly && configProject {
+			return fmt.Errorf("%s can only be set in the global config", setting.Key)
+		}
+		value, err := setting.ParseValue(name, args[1:])
+		if err != nil {
+			return err
+		}
+
+		file, err := loadScopedConfigFile()
+		if err != nil {
+			return err
+		}
+		if err := file.Set(key, value); err != nil {
+			return err
+		}
+		if err := file.Save(); err != nil {
+			return err
+		}
+
+		color.Green("Set %s in %s", key, file.Path)
+		warnIfOverridden(key)
+		return nil
+	},
+}
+
+// configUnsetCmd represents the config unset command
+var configUnsetCmd = &cobra.Command{
+	Use:     "unset <key>",
+	Short:   "Remove a setting",
+	Long:    `Removes a setting from the global config file, or the project config file with --project.`,
+	Example: `  rules config unset registries.@acme`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key := args[0]
+		if _, _, err := config.LookupSetting(key); err != nil {
+			return err
+		}
+
+		file, err := loadScopedConfigFile()
+		if err != nil {
+			return err
+		}
+		if !file.Unset(key) {
+			color.Yellow("%s is not set in %s", key, file.Path)
+			return nil
+		}
+		if err := file.Save(); err != nil {
+			return err
+		}
+
+		color.Green("Removed %s from %s", key, file.Path)
+		warnIfOverridden(key)
+		return nil
+	},
+}
+
+// configListCmd represents the config list command
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List settings and where they come from",
+	Long: `Lists the effective value of every setting and where it comes from:
+the built-in default, the global or project config file, or a RULES_*
+environment variable. With --global or --project, lists only what is set in
+that file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configGlobal || configProject {
+			file, err := loadScopedConfigFile()
+			if err != nil {
+				return err
+			}
+			for _, key := range file.Keys() {
+				value, _ := file.Get(key)
+				if _, _, err := config.LookupSetting(key); err != nil {
+					fmt.Printf("%s = %s (unknown setting)\n", key, config.FormatValue(value))
+					continue
+				}
+				if entries, ok := value.(map[string]interface{}); ok {
+					for _, name := range sortedKeys(entries) {
+						fmt.Printf("%s.%s = %s\n", key, name, config.FormatValue(entries[name]))
+					}
+					continue
+				}
+				fmt.Printf("%s = %s%s\n", key, config.FormatValue(value), ignoredSuffix(key, value))
+			}
+			return nil
+		}
+
+		resolved, err := config.Resolve("")
+		if err != nil {
+			return err
+		}
+		for _, setting := range resolved {
+			printResolvedSetting(setting, true)
+		}
+		return nil
+	},
+}
+
+// loadScopedConfigFile loads the config file selected by --global or
+// --project, defaulting to the global file
+func loadScopedConfigFile() (*config.File, error) {
+	if configProject {
+		return config.LoadFile(config.ProjectConfigPath())
+	}
+	return config.LoadFile(config.GlobalConfigPath())
+}
+
+// ignoredSuffix marks a value from the project config file that isn't used,
+// e.g. a user-only setting, so the file and the effective config agree.
+// Values from the global file are always used.
+func ignoredSuffix(key string, value interface{}) string {
+	if !configProject {
+		return ""
+	}
+	reason, err := config.IgnoredProjectValue(key, value)
+	if err != nil || reason == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (ignored: %s)", reason)
+}
+
+// printResolvedSetting prints a setting as key = value, optionally with
+// where the value comes from
+func printResolvedSetting(setting config.ResolvedSetting, showSource bool) {
+	if showSource {
+		fmt.Printf("%s = %s (%s)\n", setting.Key, config.FormatValue(setting.Value), describeSources(setting))
+	} else {
+		fmt.Printf("%s = %s\n", setting.Key, config.FormatValue(setting.Value))
+	}
+}
+
+// describeSources describes where a resolved setting comes from
+func describeSources(setting config.ResolvedSetting) string {
+	var parts []string
+	for _, source := range setting.Sources {
+		switch source {
+		case config.SourceEnv:
+			s, _, _ := config.LookupSetting(setting.Key)
+			parts = append(parts, "env "+s.EnvVar())
+		case config.SourceGlobal:
+			parts = append(parts, "global "+config.GlobalConfigPath())
+		case config.SourceProject:
+			parts = append(parts, "project "+config.ProjectConfigPath())
+		default:
+			parts = append(parts, string(source))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// warnIfOverridden warns when the file just changed doesn't decide the
+// effective value of key
+func warnIfOverridden(key string) {
+	resolved, err := config.Resolve(key)
+	if err != nil || len(resolved) != 1 {
+		return
+	}
+
+	written := config.SourceGlobal
+	if configProject {
+		written = config.SourceProject
+	}
+	sources := resolved[0].Sources
+	for _, source := range sources {
+		if source == written {
+			return
+		}
+	}
+	if sources[0] == config.SourceEnv || (sources[0] == config.SourceProject && written == config.SourceGlobal) {
+		color.Yellow("Note: %s is overridden by %s", key, describeSources(resolved[0]))
+	}
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configGetCmd, configSetCmd, configUnsetCmd, configListCmd)
+
+	configCmd.PersistentFlags().BoolVar(&configGlobal, "global", false, "Use the global config file")
+	configCmd.PersistentFlags().BoolVar(&configProject, "project", false, "Use the project config file")
+	configCmd.MarkFlagsMutuallyExclusive("global", "project")
+	configGetCmd.Flags().BoolVar(&configShowSource, "show-source", false, "Show where the value comes from")
+}
@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"rules-cli/internal/formats"
 
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 // formatsCmd represents the formats command
@@ -13,28 +15,77 @@ var formatsCmd = &cobra.Command{
 	Short: "List all available render formats",
 	Long: `List all supported render formats for the 'rules render' command.
 Each format represents a different AI code assistant platform with specific
-folder structures and file extensions.`,
-	Example: `  rules formats`,
+folder structures and file extensions.
+
+Formats are defined in YAML. Add your own by putting a definition in
+~/.rules-cli/formats/ or in the project's .rules/formats/; a definition with
+the name of a built-in format replaces it. Use 'rules formats show <name>' to
+see a definition to start from.`,
+	Example: `  rules formats
+  rules formats show cursor`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return formatsListCmd.RunE(cmd, args)
+	},
+}
+
+// formatsListCmd represents the formats list command
+var formatsListCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "List all available render formats",
+	Example: `  rules formats list`,
+	Args:    cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("Available render formats:")
 		fmt.Println()
 
 		formatList := formats.GetAllFormats()
 		for _, format := range formatList {
+			source := ""
+			if format.Source != formats.BuiltinSource {
+				source = fmt.Sprintf(" [%s]", format.Source)
+			}
 			if format.IsSingleFile {
-				fmt.Printf("%-10s - %s (%s)\n", format.Name, format.SingleFilePath, format.Description)
+				fmt.Printf("%-10s - %s (%s)%s\n", format.Name, format.SingleFilePath, format.Description, source)
 			} else {
-				fmt.Printf("%-10s - %s/*%s (%s)\n", format.Name, format.DirectoryPrefix, format.FileExtension, format.Description)
+				fmt.Printf("%-10s - %s/*%s (%s)%s\n", format.Name, format.DirectoryPrefix, format.FileExtension, format.Description, source)
 			}
 		}
 
 		fmt.Println()
 		fmt.Println("Usage: rules render <format>")
-		
+
+		return nil
+	},
+}
+
+// formatsShowCmd represents the formats show command
+var formatsShowCmd = &cobra.Command{
+	Use:   "show <format>",
+	Short: "Print the definition of a format",
+	Long: `Prints the YAML definition of a format. Save it to
+~/.rules-cli/formats/<name>.yaml or .rules/formats/<name>.yaml to change it
+or to define a new format.`,
+	Example: `  rules formats show copilot`,
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, ok := formats.LookupFormat(args[0])
+		if !ok {
+			return fmt.Errorf("unknown format %q; run 'rules formats list' to see all formats", args[0])
+		}
+
+		fmt.Printf("# Source: %s\n", format.Source)
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		defer encoder.Close()
+		if err := encoder.Encode(format); err != nil {
+			return fmt.Errorf("failed to encode format definition: %w", err)
+		}
 		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(formatsCmd)
-}
\ No newline at end of file
+	formatsCmd.AddCommand(formatsListCmd, formatsShowCmd)
+}
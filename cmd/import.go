@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"rules-cli/internal/formats"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	importForce bool
+	importSplit bool
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import <format> | --split <file>",
+	Short: "Import rules from another format into .rules",
+	Long: `Imports the rules of a tool into the default location (.rules/), the
+reverse of 'rules render'. Frontmatter is mapped back to the default format,
+e.g. Windsurf's trigger to alwaysApply and Copilot's applyTo to globs, and
+file extensions such as .mdc become .md.
+
+With --split, imports a single rules file such as CLAUDE.md, AGENTS.md,
+.cursorrules or .windsurfrules instead, given as a path or the name of a
+single file format. Each "##" section becomes an always applied rule named
+after its heading, and content before the first section is kept in a
+preamble rule.
+
+Anything that can't be converted is reported. Existing rules in .rules/ are
+not overwritten unless --force is given.`,
+	Example: `  rules import cursor
+  rules import windsurf --force
+  rules import --split CLAUDE.md
+  rules import --split .cursorrules`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesDir, err := formats.GetRulesDirectory("default")
+		if err != nil {
+			return fmt.Errorf("failed to get rules directory: %w", err)
+		}
+
+		var rules []formats.ImportedRule
+		var source string
+		if importSplit {
+			source = args[0]
+			if format, ok := formats.LookupFormat(source); ok && format.IsSingleFile {
+				source = format.SingleFilePath
+			}
+			rules, err = formats.SplitRuleFile(source)
+		} else {
+			format := formats.GetFormat(args[0])
+			source = fmt.Sprintf("%s format", format.Name)
+			rules, err = formats.ImportRules(format)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rules) == 0 {
+			color.Yellow("No rules found to import from %s", source)
+			return nil
+		}
+
+		imported, skipped, lossy := 0, 0, 0
+		for _, rule := range rules {
+			targetPath := filepath.Join(rulesDir, rule.Name)
+			if _, err := os.Stat(targetPath); err == nil && !importForce {
+				color.Yellow("Skipped %s: %s already exists (use --force to overwrite)", rule.Source, targetPath)
+				skipped++
+				continue
+			}
+
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %w", targetPath, err)
+			}
+			if err := os.WriteFile(targetPath, rule.Content, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", targetPath, err)
+			}
+
+			fmt.Printf("Imported %s -> %s\n", rule.Source, targetPath)
+			for _, loss := range rule.Losses {
+				color.Yellow("  Warning: %s", loss)
+			}
+			if len(rule.Losses) > 0 {
+				lossy++
+			}
+			imported++
+		}
+
+		color.Green("Imported %d rules from %s (%d skipped, %d with lossy conversions)", imported, source, skipped, lossy)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+	importCmd.Flags().BoolVar(&importForce, "force", false, "Overwrite existing rules")
+	importCmd.Flags().BoolVar(&importSplit, "split", false, "Split a single rules file into a rule per section")
+}
@@ -35,9 +35,9 @@ This creates the necessary directory structure and an empty rules.json file.`,
 			// Check for any top-level folder of the structure ".{folder-name}/rules"
 			formatFolders, err := formats.FindRulesFormats()
 			if err == nil && len(formatFolders) > 0 {
-				// Suggest rendering to the user
+				// Suggest importing to the user
 				color.Yellow("Found existing rules folder(s): %s", strings.Join(formatFolders, ", "))
-				color.Yellow("Consider running 'rules render %s' to initialize rules.json from existing rules", formatFolders[0])
+				color.Yellow("Consider running 'rules import %s' to import existing rules into .rules", formatFolders[0])
 			}
 		}
 
@@ -6,9 +6,7 @@ import (
 	"path/filepath"
 	"strings"
 
-	"rules-cli/internal/auth"
 	"rules-cli/internal/formats"
-	"rules-cli/internal/registry"
 	"rules-cli/internal/ruleset"
 
 	"github.com/fatih/color"
@@ -21,10 +19,11 @@ var installCmd = &cobra.Command{
 	Short: "Synchronize rules directory with rules.json",
 	Long: `Synchronizes the .rules directory with the contents of rules.json.
 Performs a clean installation by:
-- Removing all existing rule files and directories first
+- Removing the installed files of every package in rules.json first
 - Re-downloading and installing all rules specified in rules.json
 
-This ensures the rules directory matches exactly what's defined in rules.json.`,
+Rules that aren't packages from rules.json, such as ones written by hand or
+created with 'rules import', are kept.`,
 	Example: `  rules install`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if arguments were provided
@@ -84,8 +83,8 @@ This ensures the rules directory matches exactly what's defined in rules.json.`,
 		// Check if rules directory exists
 		if _, err := os.Stat(rulesDir); err == nil {
 			// Directory exists, clean it without confirmation
-			color.Cyan("Removing existing rules from '%s'...", rulesDir)
-			if err := removeContents(rulesDir); err != nil {
+			color.Cyan("Removing installed packages from '%s'...", rulesDir)
+			if err := removeInstalledRules(rulesDir, rs); err != nil {
 				return fmt.Errorf("failed to clean rules directory: %w", err)
 			}
 		} else if os.IsNotExist(err) {
@@ -97,15 +96,11 @@ This ensures the rules directory matches exactly what's defined in rules.json.`,
 			return fmt.Errorf("failed to check rules directory: %w", err)
 		}
 
-		// Create registry client
-		authConfig := auth.LoadAuthConfig()
-		client := registry.NewClient(cfg.RegistryURL)
-		client.SetAuthToken(authConfig.AccessToken)
-
 		// Install each rule from rules.json
 		color.Cyan("Installing rules from rules.json...")
 		if len(rs.Rules) == 0 {
 			color.Yellow("No rules found in rules.json.")
+			renderConfiguredTargets()
 
 			// Print format suggestion at the very end if applicable
 			if formatSuggestion != "" {
@@ -127,6 +122,7 @@ This ensures the rules directory matches exactly what's defined in rules.json.`,
 			if len(parts) > 1 {
 				ruleSlug = parts[1]
 			}
+			client := newRegistryClient(ownerSlug)
 			if err := client.DownloadRule(ownerSlug, ruleSlug, ruleVersion, rulesDir); err != nil {
 				color.Red("Error installing rule '%s': %v", ruleName, err)
 				errorCount++
@@ -137,6 +133,7 @@ This ensures the rules directory matches exactly what's defined in rules.json.`,
 
 		// Print summary
 		color.Green("\nInstallation complete: %d rules installed, %d failed", successCount, errorCount)
+		renderConfiguredTargets()
 
 		// Print format suggestion at the very end if applicable
 		if formatSuggestion != "" {
@@ -151,25 +148,25 @@ This ensures the rules directory matches exactly what's defined in rules.json.`,
 	},
 }
 
-// removeContents removes all files and directories inside the specified directory
-// but keeps the directory itself
-func removeContents(dir string) error {
-	d, err := os.Open(dir)
-	if err != nil {
-		return err
-	}
-	defer d.Close()
-
-	entries, err := d.Readdirnames(-1)
-	if err != nil {
-		return err
-	}
+// removeInstalledRules removes the directories of the packages listed in
+// rules.json from rulesDir, along with owner directories left empty.
+// Everything else, such as imported or hand-written rules, is kept.
+func removeInstalledRules(rulesDir string, rs *ruleset.RuleSet) error {
+	for ruleName := range rs.Rules {
+		// A rule name can't reach outside the rules directory
+		if !filepath.IsLocal(ruleName) {
+			continue
+		}
 
-	for _, entry := range entries {
-		err = os.RemoveAll(filepath.Join(dir, entry))
-		if err != nil {
+		ruleDir := filepath.Join(rulesDir, ruleName)
+		if err := os.RemoveAll(ruleDir); err != nil {
 			return err
 		}
+		if parentDir := filepath.Dir(ruleDir); parentDir != filepath.Clean(rulesDir) {
+			if entries, err := os.ReadDir(parentDir); err == nil && len(entries) == 0 {
+				os.Remove(parentDir)
+			}
+		}
 	}
 
 	return nil
@@ -177,4 +174,5 @@ func removeContents(dir string) error {
 
 func init() {
 	rootCmd.AddCommand(installCmd)
+	addNoRenderFlag(installCmd)
 }
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"rules-cli/internal/ruleset"
+)
+
+func TestRemoveInstalledRules(t *testing.T) {
+	rulesDir := filepath.Join(t.TempDir(), ".rules")
+	files := []string{
+		"starter/nextjs-rules/rules.md",
+		"acme/style/style.md",
+		"acme/other/other.md",
+		"imported.md",
+		"config.yaml",
+	}
+	for _, file := range files {
+		path := filepath.Join(rulesDir, file)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("# Rule\n"), 0644)
+	}
+
+	rs := ruleset.DefaultRuleSet("test")
+	rs.Rules = map[string]string{
+		"starter/nextjs-rules": "1.0.0",
+		"acme/style":           "1.0.0",
+		"../outside":           "1.0.0",
+	}
+	if err := removeInstalledRules(rulesDir, rs); err != nil {
+		t.Fatalf("removeInstalledRules failed: %v", err)
+	}
+
+	for _, removed := range []string{"starter", "acme/style"} {
+		if _, err := os.Stat(filepath.Join(rulesDir, removed)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", removed, err)
+		}
+	}
+	for _, kept := range []string{"acme/other/other.md", "imported.md", "config.yaml"} {
+		if _, err := os.Stat(filepath.Join(rulesDir, kept)); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", kept, err)
+		}
+	}
+}
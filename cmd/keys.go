@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"crypto/ed25519"
+	"fmt"
+
+	"rules-cli/internal/signing"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keyPath      string
+	overwriteKey bool
+)
+
+// keysCmd groups commands for managing package signing keys
+var keysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "Manage package signing keys",
+	Long: `Commands for managing the ed25519 key used to sign packages with
+'rules publish --sign'.
+
+Packages installed with 'rules add' and 'rules install' are checked against
+the public keys listed under 'trusted_keys' in the user or project config,
+according to 'signature_policy' (off, warn or require; default warn).`,
+}
+
+// keysGenerateCmd represents the keys generate command
+var keysGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generate a new package signing key",
+	Long: `Generates an ed25519 key pair for signing packages. The private key is
+written to ~/.rules-cli/keys/signing.key (readable only by you) and the public
+key next to it as signing.pub.
+
+Share the printed public key with the people installing your packages so
+they can add it to their trusted_keys.`,
+	Example: `  rules keys generate
+  rules keys generate --key ./release.key`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := resolveKeyPath()
+		if err != nil {
+			return err
+		}
+
+		publicKey, err := signing.GenerateKey(path, overwriteKey)
+		if err != nil {
+			return fmt.Errorf("%w\nUse --force to replace it", err)
+		}
+
+		color.Green("Wrote signing key to %s", path)
+		printPublicKey(publicKey)
+		return nil
+	},
+}
+
+// keysShowCmd represents the keys show command
+var keysShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the public key and fingerprint of the signing key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := resolveKeyPath()
+		if err != nil {
+			return err
+		}
+
+		privateKey, err := signing.LoadPrivateKey(path)
+		if err != nil {
+			return err
+		}
+
+		printPublicKey(privateKey.Public().(ed25519.PublicKey))
+		return nil
+	},
+}
+
+// resolveKeyPath returns the key path from --key, or the default key path
+func resolveKeyPath() (string, error) {
+	if keyPath != "" {
+		return keyPath, nil
+	}
+	return signing.DefaultKeyPath()
+}
+
+// printPublicKey prints a public key in the form used by trusted_keys
+func printPublicKey(publicKey ed25519.PublicKey) {
+	fmt.Printf("Fingerprint: %s\n", signing.Fingerprint(publicKey))
+	fmt.Printf("Public key:  %s\n", signing.FormatPublicKey(publicKey))
+	color.Cyan("To trust this key, add it to trusted_keys in rules-cli.yaml")
+}
+
+func init() {
+	rootCmd.AddCommand(keysCmd)
+	keysCmd.AddCommand(keysGenerateCmd)
+	keysCmd.AddCommand(keysShowCmd)
+
+	keysCmd.PersistentFlags().StringVar(&keyPath, "key", "", "Path to the private key (default is $HOME/.rules-cli/keys/signing.key)")
+	keysGenerateCmd.Flags().BoolVar(&overwriteKey, "force", false, "Replace an existing key")
+}
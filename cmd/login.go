@@ -1,27 +1,85 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"rules-cli/internal/auth"
+	"rules-cli/internal/registry"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+var (
+	loginRegistry string
+	loginDevice   bool
+	loginPaste    bool
+	loginToken    bool
+)
+
 // loginCmd represents the login command
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Authenticate with the registry service",
-	Long:  `Starts the authorization flow to authenticate with the registry service.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Starts the authorization flow to authenticate with the registry service.
+
+By default a browser is opened to sign in, and redirected back to a
+temporary server on 127.0.0.1 to complete the login. Use --paste to copy a
+token from the sign-in page and paste it instead.
+
+Use --registry to log in to a scoped registry configured under 'registries'
+in the config file. Scoped registries authenticate with a bearer token, which
+you will be prompted to paste.
+
+Use --profile to save the login under a named profile, e.g. to keep a
+personal and an employer account side by side. See 'rules auth'.
+
+Use --device on machines without a browser, such as over SSH or in a
+container. It prints a code to enter on any other device and waits for you
+to approve the login there.
+
+Use --with-token in CI and scripts: the token is read from stdin, checked
+against the registry and saved like any other login, so 'rules whoami',
+'rules auth status' and 'rules logout' work with it.`,
+	Example: `  rules login
+  rules login --device
+  rules login --paste
+  rules login --profile work
+  rules login --registry @acme
+  echo "$RULES_TOKEN" | rules login --with-token --profile ci`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Failures are reported with a failing exit code, not usage
+		cmd.SilenceUsage = true
+
+		registryURL, err := cfg.ResolveRegistry(loginRegistry)
+		if err != nil {
+			return fmt.Errorf("login failed: %w", err)
+		}
+
+		if loginToken {
+			return loginWithToken(registryURL, os.Stdin)
+		}
+
+		if !cfg.IsUserRegistry(registryURL) {
+			return loginToScopedRegistry(registryURL)
+		}
+
 		fmt.Println("Starting login process...")
 
 		// Call the login function from the auth package
-		authConfig, err := auth.Login()
+		login := auth.Login
+		if loginDevice {
+			login = auth.LoginWithDeviceCode
+		} else if loginPaste {
+			login = auth.LoginWithPastedToken
+		}
+		authConfig, err := login()
 		if err != nil {
-			color.Red("Login failed: %v", err)
-			return
+			return fmt.Errorf("login failed: %w", err)
 		}
 
 		if authConfig.UserEmail != "" {
@@ -29,9 +87,107 @@ var loginCmd = &cobra.Command{
 		} else {
 			color.Green("Successfully authenticated")
 		}
+		printLoginProfile()
+		return nil
 	},
 }
 
+// loginWithToken reads a token from stdin, checks it by fetching the account
+// it belongs to and saves it for registryURL in the active profile
+func loginWithToken(registryURL string, stdin io.Reader) error {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("failed to read token from stdin: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return errors.New("no token on stdin; pipe one in, e.g. echo \"$TOKEN\" | rules login --with-token")
+	}
+
+	client := registry.NewClient(registryURL)
+	client.SetAuthToken(token)
+	user, err := client.GetCurrentUser()
+
+	authConfig := auth.AuthConfig{AccessToken: token}
+	switch {
+	case errors.Is(err, registry.ErrUserInfoUnsupported):
+		color.Yellow("%s can't check tokens; saving it without checking", registryURL)
+	case errors.Is(err, registry.ErrCredentialsRejected):
+		return fmt.Errorf("%s rejected the token", registryURL)
+	case err != nil:
+		return fmt.Errorf("failed to check token: %w", err)
+	default:
+		authConfig.UserID = user.ID
+		authConfig.UserEmail = user.Email
+	}
+
+	if err := auth.SaveAuthConfigForRegistry(registryURL, authConfig); err != nil {
+		return fmt.Errorf("failed to save token: %w", err)
+	}
+
+	if user != nil {
+		color.Green("Logged in to %s as %s", registryURL, describeUser(user))
+	} else {
+		color.Green("Saved token for %s", registryURL)
+	}
+	printLoginProfile()
+
+	if cfg.IsUserRegistry(registryURL) && os.Getenv("CONTINUE_API_KEY") != "" {
+		color.Yellow("Note: CONTINUE_API_KEY is set and is used instead of the saved token")
+	}
+	return nil
+}
+
+// describeUser returns the username and email of an account for display
+func describeUser(user *registry.UserInfo) string {
+	switch {
+	case user.Username != "" && user.Email != "":
+		return fmt.Sprintf("%s (%s)", user.Username, user.Email)
+	case user.Username != "":
+		return user.Username
+	case user.Email != "":
+		return user.Email
+	default:
+		return user.ID
+	}
+}
+
+// loginToScopedRegistry prompts for a bearer token and saves it for registryURL
+func loginToScopedRegistry(registryURL string) error {
+	color.Cyan("Logging in to %s", registryURL)
+
+	token, err := auth.Prompt(color.YellowString("Paste your registry token here: "))
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return errors.New("login failed: token cannot be empty")
+	}
+
+	if err := auth.SaveAuthConfigForRegistry(registryURL, auth.AuthConfig{AccessToken: token}); err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+
+	color.Green("Successfully authenticated with %s", registryURL)
+	printLoginProfile()
+	return nil
+}
+
+// printLoginProfile tells the user which profile the login was saved to,
+// unless it is the default one
+func printLoginProfile() {
+	if name := auth.ActiveProfile(); name != auth.DefaultProfile {
+		fmt.Printf("Credentials saved to profile %s\n", name)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	loginCmd.Flags().StringVar(&loginRegistry, "registry", "", "Registry to log in to, as a scope (e.g. @acme) or URL")
+	loginCmd.Flags().BoolVar(&loginDevice, "device", false, "Log in by entering a code on another device, without opening a browser")
+	loginCmd.Flags().BoolVar(&loginPaste, "paste", false, "Log in by pasting a token from the sign-in page")
+	loginCmd.Flags().BoolVar(&loginToken, "with-token", false, "Read a token from stdin instead of signing in interactively")
+	loginCmd.MarkFlagsMutuallyExclusive("device", "paste", "with-token")
 }
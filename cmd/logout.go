@@ -7,18 +7,27 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var logoutRegistry string
+
 // logoutCmd represents the logout command
 var logoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Log out from the registry service",
 	Long:  `Logs the user out by removing stored authentication information.`,
 	Run: func(cmd *cobra.Command, args []string) {
+		registryURL, err := cfg.ResolveRegistry(logoutRegistry)
+		if err != nil {
+			color.Red("Logout failed: %v", err)
+			return
+		}
+
 		color.Cyan("Logging out...")
 		// Call the logout function from the auth package
-		auth.Logout()
+		auth.LogoutFromRegistry(registryURL)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(logoutCmd)
+	logoutCmd.Flags().StringVar(&logoutRegistry, "registry", "", "Registry to log out from, as a scope (e.g. @acme) or URL")
 }
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"rules-cli/internal/pack"
+	"rules-cli/internal/ruleset"
+	"rules-cli/internal/validation"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var packOutDir string
+
+// packCmd represents the pack command
+var packCmd = &cobra.Command{
+	Use:   "pack [path]",
+	Short: "Create a rule package archive without publishing it",
+	Long: `Validates rules.json and writes the package archive that 'rules publish'
+would upload to <slug>-<version>.zip, listing every file and its size.
+
+By default only rules.json, README and LICENSE files and rule markdown are
+packaged. List other files in the "files" array of rules.json, and exclude
+paths with a .rulesignore file using gitignore syntax.
+
+The archive can be inspected and later uploaded with 'rules publish --file'.`,
+	Example: `  rules pack
+  rules pack ./my-rules --out-dir dist`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var rulesPath string
+		if len(args) > 0 {
+			rulesPath = args[0]
+		}
+
+		sourceDir, rulesJSONPath := resolvePackageSource(rulesPath)
+
+		rs, err := validateRulesJSON(rulesJSONPath)
+		if err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(packOutDir, 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+
+		zipPath, files, excluded, err := packRuleset(sourceDir, rs, packOutDir)
+		if err != nil {
+			return fmt.Errorf("failed to create package: %w", err)
+		}
+
+		printPackageListing(rs, files, excluded)
+		color.Green("Wrote %s", zipPath)
+		return nil
+	},
+}
+
+// resolvePackageSource determines the package directory and rules.json path
+// from an optional path argument, which may be a directory or a rules.json file
+func resolvePackageSource(rulesPath string) (sourceDir string, rulesJSONPath string) {
+	if rulesPath == "" {
+		return ".", "rules.json"
+	}
+
+	if stat, err := os.Stat(rulesPath); err == nil && stat.IsDir() {
+		return rulesPath, filepath.Join(rulesPath, "rules.json")
+	}
+
+	return filepath.Dir(rulesPath), rulesPath
+}
+
+// validateRulesJSON validates rules.json against the schema and loads it
+func validateRulesJSON(rulesJSONPath string) (*ruleset.RuleSet, error) {
+	color.Cyan("Validating rules.json against schema...")
+	if err := validation.ValidateRulesJSONFromFile(rulesJSONPath); err != nil {
+		return nil, fmt.Errorf("schema validation failed: %w", err)
+	}
+	color.Green("✓ rules.json is valid")
+
+	rs, err := ruleset.LoadRuleSet(rulesJSONPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules.json: %w", err)
+	}
+
+	if err := checkRuleSetName(rs); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// checkRuleSetName checks that a ruleset has a name in the owner/slug form
+// used to route and store the package
+func checkRuleSetName(rs *ruleset.RuleSet) error {
+	if rs.Name == "" {
+		return fmt.Errorf("rules.json must have a 'name' field")
+	}
+
+	parts := strings.Split(rs.Name, "/")
+	if len(parts) != 2 || !isValidSlug(parts[0]) || !isValidSlug(parts[1]) {
+		return fmt.Errorf("rules.json name '%s' must be in the form owner/slug", rs.Name)
+	}
+
+	return nil
+}
+
+// packRuleset writes the package archive for the ruleset in sourceDir to outDir.
+// It returns the archive path, the packaged files and the files left out.
+func packRuleset(sourceDir string, rs *ruleset.RuleSet, outDir string) (string, []pack.File, []pack.Exclusion, error) {
+	files, excluded, err := pack.Collect(sourceDir, rs.Files)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	zipPath := filepath.Join(outDir, pack.ArchiveName(rs.Name, rs.Version))
+	if err := pack.WriteArchive(sourceDir, files, zipPath); err != nil {
+		return "", nil, nil, err
+	}
+
+	return zipPath, files, excluded, nil
+}
+
+// printPackageListing prints the files in a package and their sizes,
+// followed by the files that were left out and why
+func printPackageListing(rs *ruleset.RuleSet, files []pack.File, excluded []pack.Exclusion) {
+	color.Cyan("Package contents for %s@%s:", rs.Name, rs.Version)
+	for _, file := range files {
+		fmt.Printf("  %9s  %s\n", pack.FormatSize(file.Size), file.Path)
+	}
+	fmt.Printf("%d files, %s total\n", len(files), pack.FormatSize(pack.TotalSize(files)))
+
+	if len(excluded) > 0 {
+		color.Cyan("Excluded:")
+		for _, exclusion := range excluded {
+			fmt.Printf("  %s (%s)\n", exclusion.Path, exclusion.Reason)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(packCmd)
+
+	packCmd.Flags().StringVar(&packOutDir, "out-dir", ".", "Directory to write the package archive to")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"rules-cli/internal/pack"
+	"rules-cli/internal/registry"
+
+	"github.com/mattn/go-isatty"
+)
+
+// progressInterval limits how often the progress line is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// newProgressPrinter returns a progress callback that draws the progress of
+// a transfer on stderr, or nil if stderr isn't a terminal. The line is
+// cleared when the transfer completes.
+func newProgressPrinter() registry.ProgressFunc {
+	if !isatty.IsTerminal(os.Stderr.Fd()) && !isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		return nil
+	}
+
+	var lastDraw time.Time
+	return func(done, total int64) {
+		if done == total {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			lastDraw = time.Time{}
+			return
+		}
+
+		if time.Since(lastDraw) < progressInterval {
+			return
+		}
+		lastDraw = time.Now()
+
+		if total > 0 {
+			fmt.Fprintf(os.Stderr, "\r\033[K  %s / %s (%d%%)", pack.FormatSize(done), pack.FormatSize(total), done*100/total)
+		} else {
+			fmt.Fprintf(os.Stderr, "\r\033[K  %s", pack.FormatSize(done))
+		}
+	}
+}
@@ -1,18 +1,19 @@
 package cmd
 
 import (
-	"archive/zip"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
-	"time"
 
 	"rules-cli/internal/auth"
+	"rules-cli/internal/pack"
 	"rules-cli/internal/registry"
 	"rules-cli/internal/ruleset"
+	"rules-cli/internal/signing"
 	"rules-cli/internal/validation"
 
 	"github.com/fatih/color"
@@ -22,6 +23,11 @@ import (
 var (
 	visibility     string
 	packageVersion string
+	dryRun         bool
+	packageFile    string
+	forcePublish   bool
+	signPackage    bool
+	signingKeyPath string
 )
 
 // publishCmd represents the publish command
@@ -36,89 +42,148 @@ The slug is automatically determined from rules.json in the current directory or
 The visibility can be set to "public" (default) or "private".
 The version can be specified with --version flag, defaults to timestamp-based version.
 
+Before uploading, the registry is asked which owners you can publish to, and
+the owner in the rules.json name must be one of them.
+
+Every markdown file in the package has its frontmatter validated first, and
+problems block the upload unless --force is given.
+
+Use --dry-run to validate and pack without uploading, and --file to upload
+an archive created earlier with 'rules pack'.
+
+Use --sign to sign the package archive with the key from 'rules keys generate'.
+The signature and key fingerprint are uploaded with the package so that
+'rules add' and 'rules install' can verify it.
+
 Examples:
   rules publish                           # Publish from current directory
   rules publish ./my-rules                # Publish from specified directory
-  rules publish --visibility private      # Publish as private`,
+  rules publish --visibility private      # Publish as private
+  rules publish --dry-run                 # Show what would be published
+  rules publish --file style-1.0.0.zip    # Publish a packed archive
+  rules publish --sign                    # Sign the package before uploading`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: runPublishCommand,
 }
 
-// createPackageZip creates a zip file containing all the rule files
-func createPackageZip(rulesPath string, tempDir string) (string, error) {
-	// Create temporary zip file
-	zipFileName := fmt.Sprintf("package-%d.zip", time.Now().Unix())
-	zipPath := filepath.Join(tempDir, zipFileName)
+// loadPackedArchive validates the rules.json inside a previously packed archive
+func loadPackedArchive(zipPath string) (*ruleset.RuleSet, []pack.File, error) {
+	files, err := pack.ListArchive(zipPath)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	zipFile, err := os.Create(zipPath)
+	rulesData, err := pack.ReadArchiveFile(zipPath, "rules.json")
 	if err != nil {
-		return "", fmt.Errorf("failed to create zip file: %w", err)
+		return nil, nil, err
 	}
-	defer zipFile.Close()
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close()
+	color.Cyan("Validating rules.json against schema...")
+	if err := validation.ValidateRulesJSON(rulesData); err != nil {
+		return nil, nil, fmt.Errorf("schema validation failed: %w", err)
+	}
+	color.Green("✓ rules.json is valid")
 
-	// Determine the source directory
-	sourceDir := "."
-	if rulesPath != "" {
-		if stat, err := os.Stat(rulesPath); err == nil && stat.IsDir() {
-			sourceDir = rulesPath
-		} else {
-			sourceDir = filepath.Dir(rulesPath)
-		}
+	var rs ruleset.RuleSet
+	if err := json.Unmarshal(rulesData, &rs); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse rules.json: %w", err)
 	}
 
-	// Walk through the directory and add files to zip
-	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	if err := checkRuleSetName(&rs); err != nil {
+		return nil, nil, err
+	}
 
-		// Skip directories
-		if info.IsDir() {
-			return nil
-		}
+	return &rs, files, nil
+}
 
-		// Skip hidden files and directories
-		if strings.HasPrefix(info.Name(), ".") {
-			return nil
-		}
+// ruleFileError is a validation problem in one rule file of a package
+type ruleFileError struct {
+	File    string
+	Field   string
+	Message string
+}
+
+// validatePackageRules validates the frontmatter of every markdown file in a
+// package. readFile returns the content of a package file by its path.
+func validatePackageRules(files []pack.File, readFile func(path string) ([]byte, error)) ([]ruleFileError, int, error) {
+	var problems []ruleFileError
+	checked := 0
 
-		// Skip temporary files
-		if strings.HasSuffix(info.Name(), ".tmp") || strings.HasSuffix(info.Name(), "~") {
-			return nil
+	for _, file := range files {
+		if !strings.HasSuffix(file.Path, ".md") {
+			continue
 		}
+		checked++
 
-		// Get relative path from source directory
-		relPath, err := filepath.Rel(sourceDir, path)
+		content, err := readFile(file.Path)
 		if err != nil {
-			return err
+			return nil, checked, fmt.Errorf("failed to read %s: %w", file.Path, err)
 		}
 
-		// Create file in zip
-		zipFileWriter, err := zipWriter.Create(relPath)
+		result, err := validation.ValidateRuleContent(content)
 		if err != nil {
-			return err
+			return nil, checked, fmt.Errorf("failed to validate %s: %w", file.Path, err)
 		}
 
-		// Open source file
-		sourceFile, err := os.Open(path)
-		if err != nil {
-			return err
+		for _, validationErr := range result.Errors {
+			field := validationErr.Field
+			// Unknown keys are reported against the root, with the key in SchemaPath
+			if field == "root" && validationErr.SchemaPath != "" {
+				field = validationErr.SchemaPath
+			}
+			problems = append(problems, ruleFileError{
+				File:    file.Path,
+				Field:   field,
+				Message: validationErr.Message,
+			})
 		}
-		defer sourceFile.Close()
+	}
+
+	return problems, checked, nil
+}
 
-		// Copy file content to zip
-		_, err = io.Copy(zipFileWriter, sourceFile)
+// checkPackageRules validates the package's rule files and prints a single
+// report. Problems block publishing unless force is set.
+func checkPackageRules(files []pack.File, readFile func(path string) ([]byte, error), force bool) error {
+	color.Cyan("Validating rule files...")
+	problems, checked, err := validatePackageRules(files, readFile)
+	if err != nil {
 		return err
+	}
+
+	if len(problems) == 0 {
+		if checked == 1 {
+			color.Green("✓ 1 rule file is valid")
+		} else {
+			color.Green("✓ %d rule files are valid", checked)
+		}
+		return nil
+	}
+
+	// Schema errors come back in no particular order
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].File != problems[j].File {
+			return problems[i].File < problems[j].File
+		}
+		return problems[i].Field < problems[j].Field
 	})
 
-	if err != nil {
-		return "", fmt.Errorf("failed to create package zip: %w", err)
+	invalidFiles := make(map[string]bool)
+	for _, problem := range problems {
+		invalidFiles[problem.File] = true
+	}
+
+	color.Red("✗ Found %d problems in %d of %d rule files:", len(problems), len(invalidFiles), checked)
+	for _, problem := range problems {
+		fmt.Printf("  %s: %s: %s\n", problem.File, problem.Field, problem.Message)
+	}
+
+	if force {
+		color.Yellow("Continuing anyway because --force was given")
+		return nil
 	}
 
-	return zipPath, nil
+	return fmt.Errorf("rule file validation failed; fix the problems above or use --force to publish anyway")
 }
 
 // runPublishCommand implements the main logic for the publish command
@@ -128,88 +193,181 @@ func runPublishCommand(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("visibility must be either 'public' or 'private'")
 	}
 
-	// Determine the path to look for rules.json
-	var rulesPath string
-	if len(args) > 0 {
-		rulesPath = args[0]
+	if packageFile != "" && len(args) > 0 {
+		return fmt.Errorf("cannot use --file together with a path")
 	}
 
-	// Determine the rules.json file path
-	var rulesJSONPath string
-	if rulesPath != "" {
-		// If a path was specified, look for rules.json in that directory
-		stat, err := os.Stat(rulesPath)
-		if err == nil && stat.IsDir() {
-			rulesJSONPath = filepath.Join(rulesPath, "rules.json")
-		} else {
-			rulesJSONPath = rulesPath
+	var (
+		rs       *ruleset.RuleSet
+		zipPath  string
+		files    []pack.File
+		excluded []pack.Exclusion
+		err      error
+	)
+
+	if packageFile != "" {
+		// Upload a previously packed archive as-is
+		rs, files, err = loadPackedArchive(packageFile)
+		if err != nil {
+			return err
+		}
+		zipPath = packageFile
+
+		readFile := func(path string) ([]byte, error) {
+			return pack.ReadArchiveFile(zipPath, path)
+		}
+		if err := checkPackageRules(files, readFile, forcePublish); err != nil {
+			return err
 		}
 	} else {
-		// Look in current directory for rules.json
-		rulesJSONPath = "rules.json"
-	}
+		// Determine the path to look for rules.json
+		var rulesPath string
+		if len(args) > 0 {
+			rulesPath = args[0]
+		}
+		sourceDir, rulesJSONPath := resolvePackageSource(rulesPath)
 
-	// Validate the rules.json file against the schema FIRST
-	color.Cyan("Validating rules.json against schema...")
-	if err := validation.ValidateRulesJSONFromFile(rulesJSONPath); err != nil {
-		return fmt.Errorf("schema validation failed: %w", err)
+		// Validate the rules.json file against the schema FIRST
+		rs, err = validateRulesJSON(rulesJSONPath)
+		if err != nil {
+			return err
+		}
+
+		// Collect the package files once, validate them and pack exactly
+		// what was validated
+		files, excluded, err = pack.Collect(sourceDir, rs.Files)
+		if err != nil {
+			return fmt.Errorf("failed to create package: %w", err)
+		}
+		readFile := func(path string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(sourceDir, filepath.FromSlash(path)))
+		}
+		if err := checkPackageRules(files, readFile, forcePublish); err != nil {
+			return err
+		}
+
+		// Create temporary directory for package creation
+		tempDir, err := os.MkdirTemp("", "rules-publish-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(tempDir)
+
+		// Create package zip file
+		color.Cyan("Creating package zip file...")
+		zipPath = filepath.Join(tempDir, pack.ArchiveName(rs.Name, rs.Version))
+		if err := pack.WriteArchive(sourceDir, files, zipPath); err != nil {
+			return fmt.Errorf("failed to create package: %w", err)
+		}
 	}
-	color.Green("✓ rules.json is valid")
 
-	// Load ruleset from the specified path or current directory
-	rs, err := ruleset.LoadRuleSetFromPath(rulesPath)
-	if err != nil {
-		return fmt.Errorf("failed to load rules.json: %w", err)
+	packageVersion = rs.Version
+
+	// Packages are published to the registry that serves the owner scope
+	ownerSlug := strings.SplitN(rs.Name, "/", 2)[0]
+	registryURL := cfg.RegistryURLForOwner(ownerSlug)
+
+	metadata := registry.PublishMetadata{Visibility: visibility}
+	if signPackage || signingKeyPath != "" {
+		metadata.Signature, metadata.KeyFingerprint, err = signArchive(zipPath)
+		if err != nil {
+			return err
+		}
+		color.Green("✓ Signed package with key %s", metadata.KeyFingerprint)
 	}
 
-	// Validate that the ruleset has a name
-	if rs.Name == "" {
-		return fmt.Errorf("rules.json must have a 'name' field")
+	if dryRun {
+		printPackageListing(rs, files, excluded)
+		color.Yellow("Dry run: would publish %s (version %s) to %s with visibility: %s", rs.Name, packageVersion, registryURL, visibility)
+		return nil
 	}
 
 	// NOW ensure the user is authenticated (after validation passes)
-	authenticated, err := auth.EnsureAuthenticated(true)
-	if err != nil || !authenticated {
-		return fmt.Errorf("authentication required to publish rules")
+	if cfg.IsUserRegistry(registryURL) {
+		authenticated, err := auth.EnsureAuthenticated(true)
+		if err != nil || !authenticated {
+			return fmt.Errorf("authentication required to publish rules")
+		}
+	} else if auth.LoadAuthConfigForRegistry(registryURL).AccessToken == "" {
+		return fmt.Errorf("authentication required to publish to %s\nRun 'rules login --registry @%s' first", registryURL, ownerSlug)
 	}
 
-	// Create registry client and get user info
-	authConfig := auth.LoadAuthConfig()
-	client := registry.NewClient(cfg.RegistryURL)
-	client.SetAuthToken(authConfig.AccessToken)
-
-	// Generate version if not specified
-	packageVersion = rs.Version
-
-	// Create temporary directory for package creation
-	tempDir, err := ioutil.TempDir("", "rules-publish-")
-	if err != nil {
-		return fmt.Errorf("failed to create temporary directory: %w", err)
-	}
-	defer os.RemoveAll(tempDir)
+	// Create registry client
+	client := newRegistryClient(ownerSlug)
 
-	// Create package zip file
-	color.Cyan("Creating package zip file...")
-	zipPath, err := createPackageZip(rulesPath, tempDir)
-	if err != nil {
-		return fmt.Errorf("failed to create package: %w", err)
+	// Make sure the user can publish under this owner before uploading
+	if err := checkPublishPermission(client, ownerSlug); err != nil {
+		return err
 	}
-	defer os.Remove(zipPath)
 
 	// Publish the rule package
 	color.Cyan("Publishing package to %s (version %s) with visibility: %s", rs.Name, packageVersion, visibility)
-	err = client.PublishRule(rs.Name, packageVersion, zipPath, visibility)
+	err = client.PublishRuleWithMetadata(rs.Name, packageVersion, zipPath, metadata)
 	if err != nil {
 		return fmt.Errorf("failed to publish rule: %w", err)
 	}
 
-	// Create the URL for the published rule
-	ruleURL := fmt.Sprintf("%s/%s/versions/%s", cfg.AppURL, rs.Name, packageVersion)
 	color.Green("Successfully published package '%s' (version %s)", rs.Name, packageVersion)
-	color.Green("Your rule is now available at: %s", ruleURL)
+
+	// Only the default registry has a web app to link to
+	if cfg.IsUserRegistry(registryURL) {
+		ruleURL := fmt.Sprintf("%s/%s/versions/%s", cfg.AppURL, rs.Name, packageVersion)
+		color.Green("Your rule is now available at: %s", ruleURL)
+	} else {
+		color.Green("Your rule is now available from %s", registryURL)
+	}
+	return nil
+}
+
+// checkPublishPermission fails if the authenticated user can't publish
+// packages owned by ownerSlug. Registries that don't report permissions are
+// left to enforce them on upload.
+func checkPublishPermission(client *registry.Client, ownerSlug string) error {
+	user, err := client.GetCurrentUser()
+	if errors.Is(err, registry.ErrUserInfoUnsupported) {
+		color.Yellow("Warning: %s doesn't report publish permissions; skipping owner check", client.BaseURL)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check publish permissions: %w", err)
+	}
+
+	if !user.CanPublishTo(ownerSlug) {
+		owners := user.PublishableOwners()
+		if len(owners) == 0 {
+			return fmt.Errorf("you don't have permission to publish packages owned by '%s'", ownerSlug)
+		}
+		return fmt.Errorf("you don't have permission to publish packages owned by '%s'\nYou can publish to: %s\nChange the owner in the 'name' field of rules.json", ownerSlug, strings.Join(owners, ", "))
+	}
+
 	return nil
 }
 
+// signArchive signs a package archive with the signing key and returns the
+// signature and key fingerprint to send with the upload
+func signArchive(zipPath string) (string, string, error) {
+	path := signingKeyPath
+	if path == "" {
+		var err error
+		if path, err = signing.DefaultKeyPath(); err != nil {
+			return "", "", err
+		}
+	}
+
+	privateKey, err := signing.LoadPrivateKey(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	archive, err := os.Open(zipPath)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read package for signing: %w", err)
+	}
+	defer archive.Close()
+
+	return signing.Sign(privateKey, archive)
+}
+
 // isValidSlug checks if a slug is valid (alphanumeric, hyphens, underscores only)
 func isValidSlug(slug string) bool {
 	if slug == "" {
@@ -235,4 +393,9 @@ func init() {
 	// Add flags
 	publishCmd.Flags().StringVar(&visibility, "visibility", "public", "Set the visibility of the rule to 'public' or 'private'")
 	publishCmd.Flags().StringVar(&packageVersion, "version", "", "Version for the package (defaults to timestamp-based version)")
+	publishCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Validate and pack the package without uploading it")
+	publishCmd.Flags().StringVar(&packageFile, "file", "", "Publish a package archive created with 'rules pack'")
+	publishCmd.Flags().BoolVar(&forcePublish, "force", false, "Publish even if rule files fail validation")
+	publishCmd.Flags().BoolVar(&signPackage, "sign", false, "Sign the package with your signing key (see 'rules keys generate')")
+	publishCmd.Flags().StringVar(&signingKeyPath, "key", "", "Sign the package with the private key at this path (implies --sign)")
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"rules-cli/internal/registry"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveDir   string
+	serveAddr  string
+	serveToken string
+)
+
+// registryCmd groups commands for working with registries
+var registryCmd = &cobra.Command{
+	Use:   "registry",
+	Short: "Run and manage rule registries",
+	Long:  `Commands for running a self-hosted rule registry.`,
+}
+
+// registryServeCmd represents the registry serve command
+var registryServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a rule registry from a local directory",
+	Long: `Starts an HTTP server implementing the registry API on top of a
+filesystem directory. Packages are stored as <dir>/<owner>/<slug>/<version>.zip.
+
+Use it to run a private registry or an air-gapped mirror, then point the CLI
+at it with a scoped entry under 'registries' in the config file.
+
+If --token is given (or RULES_REGISTRY_TOKEN is set), publishing and
+downloading private packages require 'Authorization: Bearer <token>'.`,
+	Example: `  rules registry serve --dir ./registry
+  rules registry serve --dir /srv/rules --addr 0.0.0.0:8787 --token s3cret`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serveToken == "" {
+			serveToken = os.Getenv("RULES_REGISTRY_TOKEN")
+		}
+
+		if err := os.MkdirAll(serveDir, 0755); err != nil {
+			return fmt.Errorf("failed to create registry directory: %w", err)
+		}
+
+		server := registry.NewServer(serveDir, serveToken)
+
+		color.Cyan("Serving registry from %s on http://%s", serveDir, serveAddr)
+		if serveToken == "" {
+			color.Yellow("Warning: no token set, anyone who can reach this server can publish")
+		}
+
+		return server.ListenAndServe(serveAddr)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(registryCmd)
+	registryCmd.AddCommand(registryServeCmd)
+
+	registryServeCmd.Flags().StringVar(&serveDir, "dir", "registry", "Directory to store packages in")
+	registryServeCmd.Flags().StringVar(&serveAddr, "addr", "127.0.0.1:8787", "Address to listen on")
+	registryServeCmd.Flags().StringVar(&serveToken, "token", "", "Bearer token required for publishing and private downloads")
+}
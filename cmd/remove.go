@@ -85,10 +85,12 @@ For GitHub repositories, use the same gh: prefix as when adding.`,
 		}
 
 		color.Green("Rule '%s' (version %s) removed successfully", ruleName, version)
+		renderConfiguredTargets()
 		return nil
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(removeCmd)
+	addNoRenderFlag(removeCmd)
 }
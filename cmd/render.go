@@ -1,21 +1,53 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"time"
+
 	"rules-cli/internal/formats"
 
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
+// noRender disables re-rendering the configured targets after a change
+var noRender bool
+
+var (
+	renderCheck bool
+	renderDiff  bool
+	renderWatch bool
+)
+
+// watchDebounce is how long 'rules render --watch' waits for changes to
+// settle before rendering
+const watchDebounce = 200 * time.Millisecond
+
 // renderCmd represents the render command
 var renderCmd = &cobra.Command{
-	Use:   "render [format]",
-	Short: "Render rules to a specific format",
-	Long: `Renders existing rules to a specified format.
+	Use:   "render [format...]",
+	Short: "Render rules to one or more formats",
+	Long: `Renders existing rules to the specified formats.
 Copies all rules from the default location (.rules/) to the target format
 as described in render-formats.md.
 
+Without arguments, renders to the targets declared in the project config
+(e.g. 'rules config set targets cursor claude --project'). These targets are
+also re-rendered automatically by 'rules add', 'rules install' and
+'rules remove'.
+
+With --check or --diff, nothing is written. --check lists the files that
+rendering would add, change or remove, and fails if there are any, so CI can
+detect rendered files that are out of date. --diff prints the changes as
+unified diffs.
+
+With --watch, renders and then keeps watching .rules/ for changes, rendering
+the rules that changed to every target until interrupted.
+
 Supported formats:
   continue   - .continue/rules/*.md (Continue Dev rules)
   cursor     - .cursor/rules/*.mdc (Cursor rules)
@@ -25,18 +57,27 @@ Supported formats:
   codex      - AGENT.md (Codex single file)
   cline      - .clinerules/*.md (Cline rules)
   cody       - .sourcegraph/*.rule.md (Sourcegraph Cody rules)
-  amp        - AGENT.md (Amp single file)`,
+  amp        - AGENT.md (Amp single file)
+  agents     - AGENTS.md, plus <dir>/AGENTS.md for rules scoped to a directory`,
 	Example: `  rules render cursor
-  rules render continue`,
+  rules render cursor claude
+  rules render
+  rules render --check
+  rules render --diff
+  rules render --watch`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if len(args) < 1 {
-			return fmt.Errorf("format is required")
+		targets := args
+		if len(targets) == 0 {
+			targets = cfg.Targets
+		}
+		if len(targets) == 0 {
+			return fmt.Errorf("format is required, or declare targets in the project config with 'rules config set targets <format>... --project'")
 		}
 
-		formatName := args[0]
-
-		if formatName == "default" {
-			return fmt.Errorf("cannot render to default format as it is the source")
+		for _, formatName := range targets {
+			if formatName == "default" {
+				return fmt.Errorf("cannot render to default format as it is the source")
+			}
 		}
 
 		// Get the source directory
@@ -49,21 +90,158 @@ Supported formats:
 			return fmt.Errorf("source directory %s does not exist", sourceDir)
 		}
 
-		fmt.Printf("Rendering rules to %s format...\n", formatName)
+		if renderWatch && (renderCheck || renderDiff) {
+			return fmt.Errorf("--watch can't be combined with --check or --diff")
+		}
+		if renderCheck || renderDiff {
+			return checkRender(cmd, sourceDir, targets)
+		}
 
 		// Use the formats package to handle the rendering based on the target format
 		verbose, _ := cmd.Flags().GetBool("verbose")
-		if err := formats.RenderRulesToFormat(sourceDir, formatName, verbose); err != nil {
+		for _, formatName := range targets {
+			fmt.Printf("Rendering rules to %s format...\n", formatName)
+
+			result, err := formats.RenderRulesToFormat(sourceDir, formatName, verbose)
+			if err != nil {
+				return fmt.Errorf("failed to render rules to %s format: %w", formatName, err)
+			}
+			for _, path := range result.Removed {
+				fmt.Printf("Removed %s, its rule no longer exists\n", path)
+			}
+			for _, path := range result.Kept {
+				color.Yellow("Warning: kept %s: its rule no longer exists, but it was changed since it was rendered", path)
+			}
+
+			fmt.Printf("Successfully rendered rules to %s format\n", formatName)
+		}
+
+		if renderWatch {
+			return watchRender(sourceDir, targets)
+		}
+		return nil
+	},
+}
+
+// watchRender renders the rules that change in sourceDir to the targets
+// until interrupted, logging each update
+func watchRender(sourceDir string, targets []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	color.Cyan("Watching %s for changes (press Ctrl+C to stop)...", sourceDir)
+	return formats.WatchRules(ctx, sourceDir, watchDebounce, func(paths []string) {
+		timestamp := time.Now().Format("15:04:05")
+		for _, formatName := range targets {
+			result, err := formats.RenderChangedRules(sourceDir, formatName, paths)
+			if err != nil {
+				color.Yellow("[%s] Warning: failed to render rules to %s format: %v", timestamp, formatName, err)
+				continue
+			}
+			for _, path := range result.Rendered {
+				fmt.Printf("[%s] %s: rendered %s\n", timestamp, formatName, path)
+			}
+			for _, path := range result.Removed {
+				fmt.Printf("[%s] %s: removed %s\n", timestamp, formatName, path)
+			}
+			for _, path := range result.Kept {
+				color.Yellow("[%s] %s: kept %s, it was changed since it was rendered", timestamp, formatName, path)
+			}
+		}
+	})
+}
+
+// checkRender compares what rendering the targets would produce with the
+// files on disk without writing anything, printing the changes as a list
+// with --check and as unified diffs with --diff. With --check, any change
+// fails the command.
+func checkRender(cmd *cobra.Command, sourceDir string, targets []string) error {
+	var outdated []string
+	for _, formatName := range targets {
+		changes, err := formats.DiffRender(sourceDir, formatName)
+		if err != nil {
 			return fmt.Errorf("failed to render rules to %s format: %w", formatName, err)
 		}
+		if len(changes) > 0 {
+			outdated = append(outdated, formatName)
+		}
 
-		fmt.Printf("Successfully rendered rules to %s format\n", formatName)
+		for _, change := range changes {
+			if renderDiff {
+				fmt.Print(formats.UnifiedDiff(change))
+				continue
+			}
+			switch change.Kind {
+			case formats.FileAdded:
+				color.Green("  %-8s %s", change.Kind, change.Path)
+			case formats.FileRemoved:
+				color.Red("  %-8s %s", change.Kind, change.Path)
+			default:
+				color.Yellow("  %-8s %s", change.Kind, change.Path)
+			}
+		}
+	}
 
+	if len(outdated) == 0 {
+		if renderCheck {
+			color.Green("Rendered rules are up to date")
+		}
 		return nil
-	},
+	}
+	if !renderCheck {
+		return nil
+	}
+
+	// Out of date files aren't a usage error
+	cmd.SilenceUsage = true
+	return fmt.Errorf("rendered rules for %s are out of date; run 'rules render' to update them", strings.Join(outdated, ", "))
+}
+
+// renderConfiguredTargets re-renders the targets declared in the config after
+// the rules changed, unless --no-render is given. Failures are reported but
+// don't fail the command, since the change itself succeeded.
+func renderConfiguredTargets() {
+	if noRender || len(cfg.Targets) == 0 {
+		return
+	}
+
+	sourceDir, err := formats.GetRulesDirectory("default")
+	if err != nil {
+		color.Yellow("Warning: failed to get source directory: %v", err)
+		return
+	}
+	if _, err := os.Stat(sourceDir); err != nil {
+		return
+	}
+
+	var rendered []string
+	for _, formatName := range cfg.Targets {
+		result, err := formats.RenderRulesToFormat(sourceDir, formatName, false)
+		if err != nil {
+			color.Yellow("Warning: failed to render rules to %s format: %v", formatName, err)
+			continue
+		}
+		for _, path := range result.Kept {
+			color.Yellow("Warning: kept %s: its rule no longer exists, but it was changed since it was rendered", path)
+		}
+		rendered = append(rendered, formatName)
+	}
+
+	if len(rendered) > 0 {
+		color.Cyan("Rendered rules to %s", strings.Join(rendered, ", "))
+	}
+}
+
+// addNoRenderFlag adds the flag that opts out of re-rendering the configured
+// targets to a command that changes the rules
+func addNoRenderFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noRender, "no-render", false, "Don't re-render the targets declared in the config")
 }
 
 func init() {
 	rootCmd.AddCommand(renderCmd)
 	renderCmd.Flags().BoolP("verbose", "v", false, "Enable verbose output")
+	renderCmd.Flags().BoolVar(&renderCheck, "check", false, "Fail if rendered files are out of date, without writing anything")
+	renderCmd.Flags().BoolVar(&renderDiff, "diff", false, "Print how rendering would change the files, without writing anything")
+	renderCmd.Flags().BoolVar(&renderWatch, "watch", false, "Keep rendering the rules that change until interrupted")
 }
@@ -1,12 +1,20 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 
+	"rules-cli/internal/auth"
 	"rules-cli/internal/config"
+	"rules-cli/internal/formats"
+	"rules-cli/internal/registry"
+	"rules-cli/internal/signing"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // Version represents the current version of the CLI
@@ -17,6 +25,7 @@ var (
 	cfgFile string
 	cfg     *config.Config
 	format  string
+	profile string
 	version bool
 )
 
@@ -48,6 +57,7 @@ func init() {
 	// Global flags
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.rules-cli/rules-cli.yaml)")
 	rootCmd.PersistentFlags().StringVar(&format, "format", "", "rule format (default is set in config)")
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "credentials profile to use (default is the active profile)")
 
 	// Version flag
 	rootCmd.Flags().BoolVarP(&version, "version", "v", false, "Display version information")
@@ -55,15 +65,87 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
+	config.SetConfigFile(cfgFile)
+
 	var err error
 	cfg, err = config.Initialize()
 	if err != nil {
 		color.Red("Error initializing config: %v", err)
 		os.Exit(1)
 	}
+	for _, warning := range cfg.Warnings {
+		color.Yellow("Warning: %s", warning)
+	}
+	auth.SetDefaultRegistry(cfg.UserRegistryURL)
+
+	// Formats defined by the project take precedence over the user's
+	if err := formats.LoadFormatDefinitions(config.UserFormatsDir(), config.ProjectFormatsDir()); err != nil {
+		color.Red("Error loading format definitions: %v", err)
+		os.Exit(1)
+	}
+
+	position, err := config.ParseBlockPosition(cfg.BlockPosition)
+	if err != nil {
+		color.Red("Error initializing config: %v", err)
+		os.Exit(1)
+	}
+	formats.SetBlockPosition(position)
+
+	// --profile overrides the profile from the environment and config
+	if profile != "" {
+		viper.Set("profile", profile)
+		cfg.Profile = profile
+	}
 
 	// If format not specified, use default from config
 	if format == "" {
 		format = cfg.DefaultFormat
 	}
 }
+
+// newRegistryClient creates a registry client for the registry that serves
+// packages owned by ownerSlug, authenticated with that registry's credentials
+func newRegistryClient(ownerSlug string) *registry.Client {
+	return newRegistryClientForURL(cfg.RegistryURLForOwner(ownerSlug))
+}
+
+// newRegistryClientForURL creates a registry client for registryURL,
+// authenticated with that registry's credentials
+func newRegistryClientForURL(registryURL string) *registry.Client {
+	authConfig := auth.LoadAuthConfigForRegistry(registryURL)
+	client := registry.NewClient(registryURL)
+	client.GitHubBaseURL = cfg.GitHubAPIURL
+	// Sessions with a refresh token are refreshed when they expire and saved
+	// back to the registry they belong to
+	client.UseSession(registryURL, authConfig)
+	client.VerifyPackage = verifyPackageSignature
+	client.Progress = newProgressPrinter()
+	return client
+}
+
+// verifyPackageSignature checks a downloaded package against the trusted keys
+// according to the configured signature policy. A signature that doesn't
+// match the package always fails; unsigned packages and untrusted keys only
+// fail under the "require" policy.
+func verifyPackageSignature(name string, archive io.Reader, signature, fingerprint string) error {
+	policy, err := signing.ParsePolicy(cfg.SignaturePolicy)
+	if err != nil {
+		return err
+	}
+	if policy == signing.PolicyOff {
+		return nil
+	}
+
+	err = signing.Verify(archive, signature, fingerprint, cfg.TrustedKeys)
+	if err == nil {
+		color.Green("✓ Verified signature of %s (key %s)", name, fingerprint)
+		return nil
+	}
+
+	if policy == signing.PolicyWarn && (errors.Is(err, signing.ErrUnsigned) || errors.Is(err, signing.ErrUntrustedKey)) {
+		color.Yellow("Warning: %s: %v", name, err)
+		return nil
+	}
+
+	return fmt.Errorf("signature verification failed for %s: %w", name, err)
+}
@@ -1,32 +1,52 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"rules-cli/internal/auth"
 	"rules-cli/internal/registry"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
+var whoamiRegistry string
+
 // whoamiCmd represents the whoami command
 var whoamiCmd = &cobra.Command{
 	Use:   "whoami",
 	Short: "Display information about the currently authenticated user",
-	Long:  `Displays information about the currently authenticated user, including username, email, and organization.`,
+	Long: `Displays information about the currently authenticated user, including
+username, email, and the organizations they belong to with their role and
+whether they can publish packages under that organization.
+
+Use --registry to show the account for a scoped registry.`,
+	Example: `  rules whoami
+  rules whoami --registry @acme`,
 	Run: func(cmd *cobra.Command, args []string) {
+		registryURL, err := cfg.ResolveRegistry(whoamiRegistry)
+		if err != nil {
+			color.Red("Error: %v", err)
+			return
+		}
+
 		// Check if the user is authenticated
-		if !auth.IsAuthenticated() {
+		isDefault := cfg.IsUserRegistry(registryURL)
+		if (isDefault && !auth.IsAuthenticated()) || (!isDefault && auth.LoadAuthConfigForRegistry(registryURL).AccessToken == "") {
 			color.Yellow("You are not currently authenticated.")
-			fmt.Println("Use 'rules login' to authenticate.")
+			if isDefault {
+				fmt.Println("Use 'rules login' to authenticate.")
+			} else {
+				fmt.Printf("Use 'rules login --registry %s' to authenticate.\n", whoamiRegistry)
+			}
 			return
 		}
 
 		// Load auth config to get user information
-		authConfig := auth.LoadAuthConfig()
+		authConfig := auth.LoadAuthConfigForRegistry(registryURL)
 
 		// Display user information
 		color.Green("Authenticated User:")
@@ -40,24 +60,67 @@ var whoamiCmd = &cobra.Command{
 		}
 
 		// If using API key from environment variable
-		if apiKey := fmt.Sprintf("%s", authConfig.AccessToken); apiKey != "" && authConfig.UserEmail == "" {
-			if os.Getenv("CONTINUE_API_KEY") != "" {
+		if authConfig.AccessToken != "" && authConfig.UserEmail == "" {
+			if isDefault && os.Getenv("CONTINUE_API_KEY") != "" {
 				fmt.Println("Authentication method: Environment variable (CONTINUE_API_KEY)")
 			} else {
 				fmt.Println("Authentication method: Access token")
 			}
 		}
 
-		// Attempt to get additional user info from the registry API
-		apiBase := viper.GetString("api_base")
-		client, err := registry.GetAuthenticatedClient(apiBase, false)
-		if err == nil && client.IsLoggedIn {
-			// Here we would make an API call to get more user information
-			// This would typically include organization details
+		// Get the account details and permissions from the registry
+		client := newRegistryClientForURL(registryURL)
+		user, err := client.GetCurrentUser()
+		if errors.Is(err, registry.ErrUserInfoUnsupported) {
+			color.Yellow("%s doesn't provide account details", registryURL)
+			return
+		}
+		if err != nil {
+			color.Red("Failed to fetch account details: %v", err)
+			return
 		}
+
+		printUserInfo(user, authConfig)
 	},
 }
 
+// printUserInfo prints the account details returned by the registry,
+// skipping anything already shown from the local auth config
+func printUserInfo(user *registry.UserInfo, authConfig auth.AuthConfig) {
+	if user.ID != "" && user.ID != authConfig.UserID {
+		fmt.Printf("User ID: %s\n", user.ID)
+	}
+	if user.Email != "" && user.Email != authConfig.UserEmail {
+		fmt.Printf("Email: %s\n", user.Email)
+	}
+	if user.Username != "" {
+		fmt.Printf("Username: %s\n", user.Username)
+	}
+
+	orgs := user.Organizations()
+	if len(orgs) == 0 {
+		fmt.Println("Organizations: none")
+	} else {
+		fmt.Println("Organizations:")
+		for _, org := range orgs {
+			publish := "cannot publish"
+			if org.CanPublish {
+				publish = "can publish"
+			}
+			if org.Role != "" {
+				fmt.Printf("  %s (%s, %s)\n", org.Slug, org.Role, publish)
+			} else {
+				fmt.Printf("  %s (%s)\n", org.Slug, publish)
+			}
+		}
+	}
+
+	if owners := user.PublishableOwners(); len(owners) > 0 {
+		fmt.Printf("Can publish as: %s\n", strings.Join(owners, ", "))
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(whoamiCmd)
+	whoamiCmd.Flags().StringVar(&whoamiRegistry, "registry", "", "Registry to show the account for, as a scope (e.g. @acme) or URL")
 }
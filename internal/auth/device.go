@@ -0,0 +1,179 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+)
+
+// deviceCodeGrantType is the OAuth grant type for polling a device code (RFC 8628)
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultPollInterval is used when the server doesn't specify one
+const defaultPollInterval = 5 * time.Second
+
+// DeviceAuthorization is the authorization server's response to a device
+// code request
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// DeviceFlow runs the OAuth device authorization grant against an
+// authorization server
+type DeviceFlow struct {
+	// AuthorizeURL is the device authorization endpoint
+	AuthorizeURL string
+	// TokenURL is the token endpoint that is polled for the result
+	TokenURL string
+	ClientID string
+	// Sleep waits between polls; tests replace it to avoid real delays
+	Sleep func(time.Duration)
+	// Now returns the current time; tests replace it along with Sleep
+	Now func() time.Time
+}
+
+// NewDeviceFlow creates a device flow for the configured authorization server
+func NewDeviceFlow() *DeviceFlow {
+	return &DeviceFlow{
+		AuthorizeURL: workosURL("/user_management/authorize/device"),
+		TokenURL:     workosURL("/user_management/authenticate"),
+		ClientID:     viper.GetString("workos_client_id"),
+		Sleep:        time.Sleep,
+		Now:          time.Now,
+	}
+}
+
+// Start requests a device code and the code for the user to enter
+func (f *DeviceFlow) Start() (*DeviceAuthorization, error) {
+	resp, err := f.client(time.Time{}).PostForm(f.AuthorizeURL, url.Values{"client_id": {f.ClientID}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request device code: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("device code request failed with status: %s", resp.Status)
+	}
+
+	var authorization DeviceAuthorization
+	if err := json.NewDecoder(resp.Body).Decode(&authorization); err != nil {
+		return nil, fmt.Errorf("failed to parse device code response: %w", err)
+	}
+	if authorization.DeviceCode == "" || authorization.UserCode == "" || authorization.VerificationURI == "" {
+		return nil, errors.New("device code response is missing required fields")
+	}
+
+	return &authorization, nil
+}
+
+// Poll polls the token endpoint until the user approves or denies the
+// request or the device code expires. It waits the server's interval between
+// polls, and five seconds more each time the server asks it to slow down.
+func (f *DeviceFlow) Poll(authorization *DeviceAuthorization) (AuthConfig, error) {
+	interval := time.Duration(authorization.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	var deadline time.Time
+	if authorization.ExpiresIn > 0 {
+		deadline = f.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+	}
+
+	for {
+		f.Sleep(interval)
+		if !deadline.IsZero() && f.Now().After(deadline) {
+			return AuthConfig{}, errors.New("the device code expired before the request was approved; run 'rules login --device' again")
+		}
+
+		token, err := postTokenRequest(f.client(deadline), f.TokenURL, url.Values{
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {authorization.DeviceCode},
+			"client_id":   {f.ClientID},
+		})
+		if err != nil {
+			return AuthConfig{}, err
+		}
+
+		switch token.Error {
+		case "":
+			return token.authConfig(f.Now()), nil
+		case "authorization_pending":
+			continue
+		case "slow_down":
+			interval += 5 * time.Second
+			continue
+		case "access_denied":
+			return AuthConfig{}, errors.New("the login request was denied")
+		case "expired_token":
+			return AuthConfig{}, errors.New("the device code expired before the request was approved; run 'rules login --device' again")
+		default:
+			if token.ErrorDescription != "" {
+				return AuthConfig{}, fmt.Errorf("login failed: %s: %s", token.Error, token.ErrorDescription)
+			}
+			return AuthConfig{}, fmt.Errorf("login failed: %s", token.Error)
+		}
+	}
+}
+
+// client returns an HTTP client for the authorization server. Requests time
+// out after requestTimeout, or when the device code expires if that is
+// sooner, so a poll can't outlive the code.
+func (f *DeviceFlow) client(deadline time.Time) *http.Client {
+	timeout := requestTimeout
+	if !deadline.IsZero() {
+		if remaining := deadline.Sub(f.Now()); remaining > 0 && remaining < timeout {
+			timeout = remaining
+		}
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// LoginWithDeviceCode authenticates with the device authorization grant,
+// which works without a local browser (over SSH, in containers or Codespaces)
+func LoginWithDeviceCode() (AuthConfig, error) {
+	// If CONTINUE_API_KEY environment variable exists, use that instead
+	if apiKey := os.Getenv("CONTINUE_API_KEY"); apiKey != "" {
+		color.Green("Using CONTINUE_API_KEY from environment variables")
+		return AuthConfig{
+			AccessToken: apiKey,
+		}, nil
+	}
+
+	flow := NewDeviceFlow()
+
+	color.Cyan("\nStarting device login with Continue...")
+	authorization, err := flow.Start()
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
+	color.Green("To sign in, visit: %s", authorization.VerificationURI)
+	color.Green("And enter the code: %s", authorization.UserCode)
+	if authorization.VerificationURIComplete != "" {
+		fmt.Printf("Or open %s to skip entering the code\n", authorization.VerificationURIComplete)
+	}
+	color.Yellow("\nWaiting for you to approve the request...")
+
+	authConfig, err := flow.Poll(authorization)
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
+	SaveAuthConfig(authConfig)
+
+	color.Green("\nAuthentication successful!")
+	return authConfig, nil
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newStandInAuthServer serves the device authorization endpoints, answering
+// token polls with the given errors in order before issuing a token
+func newStandInAuthServer(t *testing.T, pollErrors []string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /user_management/authorize/device", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "client_test" {
+			t.Errorf("Expected client_id client_test, got %q", r.FormValue("client_id"))
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"device_code":      "device-123",
+			"user_code":        "ABCD-EFGH",
+			"verification_uri": "https://example.com/device",
+			"expires_in":       900,
+			"interval":         2,
+		})
+	})
+	mux.HandleFunc("POST /user_management/authenticate", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != deviceCodeGrantType || r.FormValue("device_code") != "device-123" {
+			t.Errorf("Unexpected token request: %v", r.Form)
+		}
+		if len(pollErrors) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": pollErrors[0]})
+			pollErrors = pollErrors[1:]
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access-token",
+			"refresh_token": "refresh-token",
+			"user":          map[string]string{"id": "user_1", "email": "dev@example.com"},
+		})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestDeviceFlow returns a flow against server that records its waits
+// on a fake clock instead of sleeping
+func newTestDeviceFlow(server *httptest.Server, waits *[]time.Duration) *DeviceFlow {
+	now := time.Unix(1700000000, 0)
+	return &DeviceFlow{
+		AuthorizeURL: server.URL + "/user_management/authorize/device",
+		TokenURL:     server.URL + "/user_management/authenticate",
+		ClientID:     "client_test",
+		Sleep: func(d time.Duration) {
+			*waits = append(*waits, d)
+			now = now.Add(d)
+		},
+		Now: func() time.Time { return now },
+	}
+}
+
+func TestDeviceFlowPolling(t *testing.T) {
+	server := newStandInAuthServer(t, []string{"authorization_pending", "slow_down", "authorization_pending"})
+	var waits []time.Duration
+	flow := newTestDeviceFlow(server, &waits)
+
+	authorization, err := flow.Start()
+	if err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	if authorization.UserCode != "ABCD-EFGH" {
+		t.Errorf("Expected user code ABCD-EFGH, got %q", authorization.UserCode)
+	}
+
+	authConfig, err := flow.Poll(authorization)
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if authConfig.AccessToken != "access-token" || authConfig.RefreshToken != "refresh-token" {
+		t.Errorf("Unexpected tokens: %+v", authConfig)
+	}
+	if authConfig.UserEmail != "dev@example.com" {
+		t.Errorf("Expected email dev@example.com, got %q", authConfig.UserEmail)
+	}
+
+	// slow_down adds five seconds to every later wait
+	expected := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second}
+	if len(waits) != len(expected) {
+		t.Fatalf("Expected waits %v, got %v", expected, waits)
+	}
+	for i := range expected {
+		if waits[i] != expected[i] {
+			t.Errorf("Expected waits %v, got %v", expected, waits)
+			break
+		}
+	}
+}
+
+func TestDeviceFlowStopsPolling(t *testing.T) {
+	tests := []struct {
+		name       string
+		pollErrors []string
+		expiresIn  int
+		expectErr  string
+	}{
+		{"denied", []string{"access_denied"}, 900, "denied"},
+		{"expired token", []string{"expired_token"}, 900, "expired"},
+		{"unknown error", []string{"invalid_grant"}, 900, "invalid_grant"},
+		{"deadline", []string{"authorization_pending", "authorization_pending", "authorization_pending"}, 5, "expired"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStandInAuthServer(t, tt.pollErrors)
+			var waits []time.Duration
+			flow := newTestDeviceFlow(server, &waits)
+
+			authorization, err := flow.Start()
+			if err != nil {
+				t.Fatalf("Start failed: %v", err)
+			}
+			authorization.ExpiresIn = tt.expiresIn
+
+			_, err = flow.Poll(authorization)
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestDeviceFlowClientTimeout(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	var waits []time.Duration
+	flow := newTestDeviceFlow(server, &waits)
+
+	if timeout := flow.client(time.Time{}).Timeout; timeout != requestTimeout {
+		t.Errorf("Expected requests to time out after %s, got %s", requestTimeout, timeout)
+	}
+	if timeout := flow.client(flow.Now().Add(time.Hour)).Timeout; timeout != requestTimeout {
+		t.Errorf("Expected a distant deadline to keep the %s timeout, got %s", requestTimeout, timeout)
+	}
+	// A poll can't outlive the device code
+	if timeout := flow.client(flow.Now().Add(3 * time.Second)).Timeout; timeout != 3*time.Second {
+		t.Errorf("Expected requests to time out when the code expires, got %s", timeout)
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// loopbackCallbackPath is where the browser is redirected after signing in
+const loopbackCallbackPath = "/callback"
+
+// loopbackTimeout is how long Login waits for the browser to sign in
+const loopbackTimeout = 5 * time.Minute
+
+// ErrStateMismatch is the response to a login callback whose state parameter
+// doesn't match the one sent with the sign-in request, which means the
+// callback didn't come from the sign-in we started
+var ErrStateMismatch = errors.New("login callback state does not match; the sign-in request may have been forged")
+
+// callbackResult is the outcome of the first login callback
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// LoopbackServer receives the authorization code on a short-lived HTTP
+// listener on 127.0.0.1, so the user doesn't have to paste a token
+type LoopbackServer struct {
+	listener net.Listener
+	server   *http.Server
+	state    string
+	results  chan callbackResult
+}
+
+// StartLoopbackServer listens on a random port on 127.0.0.1 for a login
+// callback carrying the given state
+func StartLoopbackServer(state string) (*LoopbackServer, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start local callback server: %w", err)
+	}
+
+	s := &LoopbackServer{
+		listener: listener,
+		state:    state,
+		results:  make(chan callbackResult, 1),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+loopbackCallbackPath, s.handleCallback)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go s.server.Serve(listener)
+
+	return s, nil
+}
+
+// RedirectURI returns the callback URL to send as redirect_uri
+func (s *LoopbackServer) RedirectURI() string {
+	return fmt.Sprintf("http://%s%s", s.listener.Addr().String(), loopbackCallbackPath)
+}
+
+// handleCallback checks the state and records the code from the first
+// callback. Callbacks with the wrong state are rejected without ending the
+// login, so a forged request can't stop the real one from completing.
+func (s *LoopbackServer) handleCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("state") != s.state {
+		http.Error(w, ErrStateMismatch.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result callbackResult
+	switch {
+	case query.Get("error") != "":
+		if description := query.Get("error_description"); description != "" {
+			result.err = fmt.Errorf("sign-in failed: %s: %s", query.Get("error"), description)
+		} else {
+			result.err = fmt.Errorf("sign-in failed: %s", query.Get("error"))
+		}
+	case query.Get("code") == "":
+		result.err = errors.New("sign-in callback is missing the authorization code")
+	default:
+		result.code = query.Get("code")
+	}
+
+	if result.err != nil {
+		http.Error(w, result.err.Error(), http.StatusBadRequest)
+	} else {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body><h3>Signed in to rules</h3><p>You can close this window and return to your terminal.</p></body></html>")
+	}
+
+	// Only the first callback counts
+	select {
+	case s.results <- result:
+	default:
+	}
+}
+
+// Wait waits for the login callback and returns its authorization code
+func (s *LoopbackServer) Wait(timeout time.Duration) (string, error) {
+	select {
+	case result := <-s.results:
+		return result.code, result.err
+	case <-time.After(timeout):
+		return "", fmt.Errorf("timed out after %s waiting for sign-in to complete", timeout)
+	}
+}
+
+// Close stops the listener
+func (s *LoopbackServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+}
+
+// ExchangeCode exchanges an authorization code from a login callback for
+// tokens, proving possession of the PKCE code verifier. redirectURI must be
+// the one the sign-in request was sent with.
+func ExchangeCode(code, codeVerifier, redirectURI string) (AuthConfig, error) {
+	token, err := postTokenRequest(&http.Client{Timeout: requestTimeout}, workosURL("/user_management/authenticate"), url.Values{
+		"grant_type":    {"authorization_code"},
+		"client_id":     {viper.GetString("workos_client_id")},
+		"code":          {code},
+		"code_verifier": {codeVerifier},
+		"redirect_uri":  {redirectURI},
+	})
+	if err != nil {
+		return AuthConfig{}, err
+	}
+	if token.Error != "" {
+		if token.ErrorDescription != "" {
+			return AuthConfig{}, fmt.Errorf("failed to exchange code: %s: %s", token.Error, token.ErrorDescription)
+		}
+		return AuthConfig{}, fmt.Errorf("failed to exchange code: %s", token.Error)
+	}
+
+	return token.authConfig(time.Now()), nil
+}
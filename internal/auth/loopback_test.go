@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestLoopbackServerCallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		query      url.Values
+		expectCode string
+		expectErr  string
+	}{
+		{"valid callback", url.Values{"state": {"state-1"}, "code": {"code-1"}}, "code-1", ""},
+		{"sign-in error", url.Values{"state": {"state-1"}, "error": {"access_denied"}}, "", "access_denied"},
+		{"missing code", url.Values{"state": {"state-1"}}, "", "missing the authorization code"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := StartLoopbackServer("state-1")
+			if err != nil {
+				t.Fatalf("StartLoopbackServer failed: %v", err)
+			}
+			defer server.Close()
+
+			redirectURI := server.RedirectURI()
+			if !strings.HasPrefix(redirectURI, "http://127.0.0.1:") {
+				t.Errorf("Expected a 127.0.0.1 redirect URI, got %s", redirectURI)
+			}
+
+			// Simulate the browser following the redirect
+			resp, err := http.Get(redirectURI + "?" + tt.query.Encode())
+			if err != nil {
+				t.Fatalf("Callback request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			code, err := server.Wait(time.Second)
+			if tt.expectErr == "" {
+				if err != nil {
+					t.Fatalf("Wait failed: %v", err)
+				}
+				if resp.StatusCode != http.StatusOK {
+					t.Errorf("Expected callback status 200, got %d", resp.StatusCode)
+				}
+				if code != tt.expectCode {
+					t.Errorf("Expected code %q, got %q", tt.expectCode, code)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected callback status 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestLoopbackServerRejectsForgedCallbacks(t *testing.T) {
+	server, err := StartLoopbackServer("state-1")
+	if err != nil {
+		t.Fatalf("StartLoopbackServer failed: %v", err)
+	}
+	defer server.Close()
+
+	for _, query := range []string{"state=forged&code=forged", "code=forged", "state=forged&error=access_denied"} {
+		resp, err := http.Get(server.RedirectURI() + "?" + query)
+		if err != nil {
+			t.Fatalf("Callback request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("%s: expected callback status 400, got %d", query, resp.StatusCode)
+		}
+	}
+
+	// Forged callbacks don't end the login, the real one still completes it
+	resp, err := http.Get(server.RedirectURI() + "?state=state-1&code=code-1")
+	if err != nil {
+		t.Fatalf("Callback request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if code, err := server.Wait(time.Second); err != nil || code != "code-1" {
+		t.Errorf("Expected code-1, got %q, %v", code, err)
+	}
+}
+
+func TestLoopbackServerIgnoresLaterCallbacks(t *testing.T) {
+	server, err := StartLoopbackServer("state-1")
+	if err != nil {
+		t.Fatalf("StartLoopbackServer failed: %v", err)
+	}
+	defer server.Close()
+
+	for _, code := range []string{"code-1", "code-2"} {
+		resp, err := http.Get(server.RedirectURI() + "?state=state-1&code=" + code)
+		if err != nil {
+			t.Fatalf("Callback request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if code, err := server.Wait(time.Second); err != nil || code != "code-1" {
+		t.Errorf("Expected the first callback's code, got %q, %v", code, err)
+	}
+}
+
+func TestLoopbackServerTimeout(t *testing.T) {
+	server, err := StartLoopbackServer("state-1")
+	if err != nil {
+		t.Fatalf("StartLoopbackServer failed: %v", err)
+	}
+	defer server.Close()
+
+	if _, err := server.Wait(10 * time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+}
+
+func TestExchangeCode(t *testing.T) {
+	verifier, challenge, err := newPKCE()
+	if err != nil {
+		t.Fatalf("newPKCE failed: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user_management/authenticate" || r.FormValue("grant_type") != "authorization_code" {
+			t.Errorf("Unexpected token request: %s %v", r.URL.Path, r.Form)
+		}
+		if r.FormValue("redirect_uri") != "http://127.0.0.1:1234/callback" {
+			t.Errorf("Expected the sign-in redirect_uri, got %q", r.FormValue("redirect_uri"))
+		}
+		if r.FormValue("code") != "code-1" || r.FormValue("code_verifier") != verifier {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access-token",
+			"refresh_token": "refresh-token",
+			"user":          map[string]string{"id": "user_1", "email": "dev@example.com"},
+		})
+	}))
+	defer server.Close()
+
+	viper.Set("workos_api_url", server.URL)
+	defer viper.Reset()
+
+	authURL := GetAuthUrlForTokenPage("http://127.0.0.1:1234/callback", "state-1", challenge)
+	if !strings.HasPrefix(authURL, server.URL+"/user_management/authorize?") {
+		t.Errorf("Expected sign-in URL on the configured WorkOS API, got %s", authURL)
+	}
+	for _, param := range []string{"redirect_uri=http%3A%2F%2F127.0.0.1%3A1234%2Fcallback", "state=state-1", "code_challenge=" + challenge, "code_challenge_method=S256"} {
+		if !strings.Contains(authURL, param) {
+			t.Errorf("Expected sign-in URL to contain %s, got %s", param, authURL)
+		}
+	}
+
+	authConfig, err := ExchangeCode("code-1", verifier, "http://127.0.0.1:1234/callback")
+	if err != nil {
+		t.Fatalf("ExchangeCode failed: %v", err)
+	}
+	if authConfig.AccessToken != "access-token" || authConfig.UserEmail != "dev@example.com" {
+		t.Errorf("Unexpected auth config: %+v", authConfig)
+	}
+
+	if _, err := ExchangeCode("code-1", "wrong-verifier", "http://127.0.0.1:1234/callback"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("Expected invalid_grant error, got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// tokenResponse is a successful or failed response from the token endpoint
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	User         struct {
+		ID    string `json:"id"`
+		Email string `json:"email"`
+	} `json:"user"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// authConfig converts a successful token response to an AuthConfig
+func (t *tokenResponse) authConfig(now time.Time) AuthConfig {
+	expiresIn := time.Duration(t.ExpiresIn) * time.Second
+	if expiresIn <= 0 {
+		// Same assumption as RefreshToken
+		expiresIn = time.Hour
+	}
+	return AuthConfig{
+		UserID:       t.User.ID,
+		UserEmail:    t.User.Email,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresAt:    now.Add(expiresIn).UnixMilli(),
+	}
+}
+
+// workosURL returns the URL of an endpoint on the configured WorkOS API
+func workosURL(path string) string {
+	return strings.TrimRight(viper.GetString("workos_api_url"), "/") + path
+}
+
+// requestTimeout bounds each request to the authorization server, so a
+// server that stops responding can't hang the login
+const requestTimeout = 30 * time.Second
+
+// postTokenRequest posts a form to a token endpoint. OAuth errors such as
+// authorization_pending are returned in the response, not as an error.
+func postTokenRequest(client *http.Client, tokenURL string, form url.Values) (*tokenResponse, error) {
+	resp, err := client.PostForm(tokenURL, form)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("token request failed with status: %s", resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK && token.Error == "" {
+		return nil, fmt.Errorf("token request failed with status: %s", resp.Status)
+	}
+
+	return &token, nil
+}
+
+// newPKCE returns a PKCE code verifier and its S256 code challenge (RFC 7636)
+func newPKCE() (verifier string, challenge string, err error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", fmt.Errorf("failed to generate code verifier: %w", err)
+	}
+	verifier = base64.RawURLEncoding.EncodeToString(data)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// DefaultProfile is the profile used when none is selected
+const DefaultProfile = "default"
+
+// credentialsPath is the file all saved credentials are kept in
+var credentialsPath = filepath.Join(homedir, ".rules-cli", "credentials.json")
+
+// credentialStore is the contents of the credentials file: named profiles,
+// each holding credentials for any number of registries
+type credentialStore struct {
+	// ActiveProfile is the profile selected with 'rules auth switch'
+	ActiveProfile string              `json:"activeProfile,omitempty"`
+	Profiles      map[string]*profile `json:"profiles"`
+}
+
+// profile holds the credentials of one identity, keyed by registry URL
+type profile struct {
+	Registries map[string]AuthConfig `json:"registries"`
+}
+
+// ProfileInfo describes a saved profile for listing
+type ProfileInfo struct {
+	Name string
+	// Registries maps each registry URL to the credentials saved for it
+	Registries map[string]AuthConfig
+}
+
+// loadStore reads the credentials file, returning an empty store if it
+// doesn't exist yet
+func loadStore() (*credentialStore, error) {
+	store := &credentialStore{Profiles: map[string]*profile{}}
+
+	data, err := os.ReadFile(credentialsPath)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials: %w", err)
+	}
+
+	if err := json.Unmarshal(data, store); err != nil {
+		return nil, fmt.Errorf("failed to parse credentials file %s: %w", credentialsPath, err)
+	}
+	if store.Profiles == nil {
+		store.Profiles = map[string]*profile{}
+	}
+
+	return store, nil
+}
+
+// save writes the store to the credentials file
+func (s *credentialStore) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode credentials: %w", err)
+	}
+	return writeFileAtomic(credentialsPath, data, 0600)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partly written file and the file is
+// never readable with broader permissions than perm
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create credentials directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write credentials: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", path, err)
+	}
+	return nil
+}
+
+// registryKey normalizes a registry URL for use as a key in a profile.
+// An empty URL means the default registry.
+func registryKey(registryURL string) string {
+	if registryURL == "" {
+		registryURL = defaultRegistryURL
+	}
+	if registryURL == "" {
+		registryURL = viper.GetString("registry_url")
+	}
+	return strings.TrimRight(registryURL, "/")
+}
+
+// ActiveProfile returns the profile credentials are read from and saved to:
+// the "profile" setting (from --profile, RULES_PROFILE or the config) if set,
+// otherwise the profile selected with 'rules auth switch'
+func ActiveProfile() string {
+	if name := viper.GetString("profile"); name != "" {
+		return name
+	}
+	if store, err := loadStore(); err == nil && store.ActiveProfile != "" {
+		return store.ActiveProfile
+	}
+	return DefaultProfile
+}
+
+// SwitchProfile makes name the active profile. The profile must already have
+// saved credentials.
+func SwitchProfile(name string) error {
+	store, err := loadStore()
+	if err != nil {
+		return err
+	}
+	if _, ok := store.Profiles[name]; !ok {
+		return fmt.Errorf("no profile named %q; log in with 'rules login --profile %s' to create it", name, name)
+	}
+
+	store.ActiveProfile = name
+	return store.save()
+}
+
+// ListProfiles returns the saved profiles sorted by name
+func ListProfiles() ([]ProfileInfo, error) {
+	store, err := loadStore()
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []ProfileInfo
+	for name, p := range store.Profiles {
+		profiles = append(profiles, ProfileInfo{Name: name, Registries: p.Registries})
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	return profiles, nil
+}
+
+// loadProfileCredentials returns the credentials saved in the active profile
+// for a registry. Credentials saved by earlier versions, in ~/.continue, are
+// still read for the default profile.
+func loadProfileCredentials(registryURL string) (AuthConfig, bool) {
+	name := ActiveProfile()
+	key := registryKey(registryURL)
+
+	store, err := loadStore()
+	if err != nil {
+		fmt.Printf("Error loading auth config: %v\n", err)
+		return AuthConfig{}, false
+	}
+	if p, ok := store.Profiles[name]; ok {
+		if config, ok := p.Registries[key]; ok {
+			return config, true
+		}
+	}
+
+	if name != DefaultProfile {
+		return AuthConfig{}, false
+	}
+	config, ok := loadLegacyCredentials(registryURL)
+	if ok {
+		migrateLegacyCredentials(registryURL, config)
+	}
+	return config, ok
+}
+
+// saveProfileCredentials saves credentials for a registry in the active profile
+func saveProfileCredentials(registryURL string, config AuthConfig) error {
+	name := ActiveProfile()
+
+	store, err := loadStore()
+	if err != nil {
+		return err
+	}
+
+	p, ok := store.Profiles[name]
+	if !ok {
+		p = &profile{}
+		store.Profiles[name] = p
+	}
+	if p.Registries == nil {
+		p.Registries = map[string]AuthConfig{}
+	}
+	p.Registries[registryKey(registryURL)] = config
+
+	// The first profile logged in to becomes the active one
+	if store.ActiveProfile == "" {
+		store.ActiveProfile = name
+	}
+
+	if err := store.save(); err != nil {
+		return err
+	}
+
+	// The new credentials replace any an earlier version left behind
+	if name == DefaultProfile {
+		removeLegacyCredentials(registryURL)
+	}
+	return nil
+}
+
+// removeProfileCredentials removes the credentials for a registry from the
+// active profile, reporting whether there were any
+func removeProfileCredentials(registryURL string) (bool, error) {
+	name := ActiveProfile()
+
+	store, err := loadStore()
+	if err != nil {
+		return false, err
+	}
+
+	removed := false
+	if p, ok := store.Profiles[name]; ok {
+		if _, ok := p.Registries[registryKey(registryURL)]; ok {
+			delete(p.Registries, registryKey(registryURL))
+			if len(p.Registries) == 0 {
+				delete(store.Profiles, name)
+			}
+			if err := store.save(); err != nil {
+				return false, err
+			}
+			removed = true
+		}
+	}
+
+	// Otherwise the legacy credentials would be picked up again
+	if name == DefaultProfile && removeLegacyCredentials(registryURL) {
+		removed = true
+	}
+
+	return removed, nil
+}
+
+// legacyCredentialsPath returns where earlier versions saved credentials
+// for a registry
+func legacyCredentialsPath(registryURL string) string {
+	if isDefaultRegistry(registryURL) {
+		return authConfigPath
+	}
+	return registryAuthConfigPath(registryURL)
+}
+
+// loadLegacyCredentials reads credentials saved by earlier versions
+func loadLegacyCredentials(registryURL string) (AuthConfig, bool) {
+	data, err := os.ReadFile(legacyCredentialsPath(registryURL))
+	if err != nil {
+		return AuthConfig{}, false
+	}
+
+	var config AuthConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		fmt.Printf("Error loading auth config: %v\n", err)
+		return AuthConfig{}, false
+	}
+	return config, true
+}
+
+// migrateLegacyCredentials moves credentials saved by earlier versions, which
+// may be readable by other users, into the credentials file. If they can't be
+// saved there, the old file is at least made private.
+func migrateLegacyCredentials(registryURL string, config AuthConfig) {
+	if err := saveProfileCredentials(registryURL, config); err != nil {
+		os.Chmod(legacyCredentialsPath(registryURL), 0600)
+	}
+}
+
+// removeLegacyCredentials deletes credentials saved by earlier versions,
+// reporting whether there were any
+func removeLegacyCredentials(registryURL string) bool {
+	return os.Remove(legacyCredentialsPath(registryURL)) == nil
+}
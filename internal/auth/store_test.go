@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// useTempCredentials points the credentials file and the legacy auth file at
+// a temporary directory for the duration of a test
+func useTempCredentials(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	oldCredentials, oldAuthConfig := credentialsPath, authConfigPath
+	credentialsPath = filepath.Join(dir, ".rules-cli", "credentials.json")
+	authConfigPath = filepath.Join(dir, ".continue", "auth.json")
+	t.Setenv("CONTINUE_API_KEY", "")
+	viper.Set("registry_url", "https://registry.example.com")
+	t.Cleanup(func() {
+		credentialsPath, authConfigPath = oldCredentials, oldAuthConfig
+		SetDefaultRegistry("")
+		viper.Reset()
+	})
+	return dir
+}
+
+func TestSaveAuthConfigPermissions(t *testing.T) {
+	dir := useTempCredentials(t)
+
+	SaveAuthConfig(AuthConfig{AccessToken: "token-1", UserEmail: "me@example.com"})
+
+	info, err := os.Stat(credentialsPath)
+	if err != nil {
+		t.Fatalf("Credentials not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected credentials mode 0600, got %o", info.Mode().Perm())
+	}
+
+	// Nothing is left behind from the atomic write
+	entries, _ := os.ReadDir(filepath.Join(dir, ".rules-cli"))
+	if len(entries) != 1 {
+		t.Errorf("Expected only the credentials file, got %v", entries)
+	}
+
+	if config := LoadAuthConfig(); config.AccessToken != "token-1" {
+		t.Errorf("Expected saved token, got %+v", config)
+	}
+}
+
+func TestProfilesKeepSeparateCredentials(t *testing.T) {
+	useTempCredentials(t)
+
+	SaveAuthConfig(AuthConfig{AccessToken: "personal"})
+	if err := SaveAuthConfigForRegistry("https://scoped.example.com/", AuthConfig{AccessToken: "scoped"}); err != nil {
+		t.Fatalf("SaveAuthConfigForRegistry failed: %v", err)
+	}
+
+	viper.Set("profile", "work")
+	SaveAuthConfig(AuthConfig{AccessToken: "employer"})
+	if config := LoadAuthConfig(); config.AccessToken != "employer" {
+		t.Errorf("Expected work profile token, got %q", config.AccessToken)
+	}
+	if config := LoadAuthConfigForRegistry("https://scoped.example.com"); config.AccessToken != "" {
+		t.Errorf("Expected no scoped credentials in work profile, got %q", config.AccessToken)
+	}
+
+	// Without an explicit profile, the first profile logged in to is active
+	viper.Set("profile", "")
+	if ActiveProfile() != DefaultProfile {
+		t.Errorf("Expected active profile %s, got %s", DefaultProfile, ActiveProfile())
+	}
+	if config := LoadAuthConfig(); config.AccessToken != "personal" {
+		t.Errorf("Expected default profile token, got %q", config.AccessToken)
+	}
+	if config := LoadAuthConfigForRegistry("https://scoped.example.com"); config.AccessToken != "scoped" {
+		t.Errorf("Expected scoped token, got %q", config.AccessToken)
+	}
+
+	if err := SwitchProfile("work"); err != nil {
+		t.Fatalf("SwitchProfile failed: %v", err)
+	}
+	if config := LoadAuthConfig(); config.AccessToken != "employer" {
+		t.Errorf("Expected work profile token after switching, got %q", config.AccessToken)
+	}
+	if err := SwitchProfile("missing"); err == nil {
+		t.Error("Expected switching to an unknown profile to fail")
+	}
+
+	profiles, err := ListProfiles()
+	if err != nil {
+		t.Fatalf("ListProfiles failed: %v", err)
+	}
+	if len(profiles) != 2 || profiles[0].Name != "default" || profiles[1].Name != "work" {
+		t.Errorf("Unexpected profiles: %+v", profiles)
+	}
+	if len(profiles[0].Registries) != 2 {
+		t.Errorf("Expected 2 registries in default profile, got %v", profiles[0].Registries)
+	}
+}
+
+func TestLegacyCredentials(t *testing.T) {
+	useTempCredentials(t)
+
+	os.MkdirAll(filepath.Dir(authConfigPath), 0755)
+	os.WriteFile(authConfigPath, []byte(`{"accessToken":"legacy"}`), 0644)
+
+	if config := LoadAuthConfig(); config.AccessToken != "legacy" {
+		t.Errorf("Expected legacy token for default profile, got %q", config.AccessToken)
+	}
+
+	// The legacy file is moved into the private credentials file
+	if _, err := os.Stat(authConfigPath); !os.IsNotExist(err) {
+		t.Errorf("Expected legacy auth file to be migrated, got %v", err)
+	}
+	if info, err := os.Stat(credentialsPath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected legacy token in credentials file with mode 0600, got %v", err)
+	}
+	if config := LoadAuthConfig(); config.AccessToken != "legacy" {
+		t.Errorf("Expected migrated legacy token, got %q", config.AccessToken)
+	}
+
+	viper.Set("profile", "work")
+	if config := LoadAuthConfig(); config.AccessToken != "" {
+		t.Errorf("Expected legacy token to be ignored for other profiles, got %q", config.AccessToken)
+	}
+
+	// Logging out removes the legacy file so it isn't picked up again
+	viper.Set("profile", "")
+	os.WriteFile(authConfigPath, []byte(`{"accessToken":"legacy"}`), 0644)
+	Logout()
+	if _, err := os.Stat(authConfigPath); !os.IsNotExist(err) {
+		t.Errorf("Expected legacy auth file to be removed, got %v", err)
+	}
+	if config := LoadAuthConfig(); config.AccessToken != "" {
+		t.Errorf("Expected no credentials after logout, got %q", config.AccessToken)
+	}
+}
+
+func TestDefaultCredentialsStayWithUserRegistry(t *testing.T) {
+	useTempCredentials(t)
+	SetDefaultRegistry("https://registry.example.com")
+
+	SaveAuthConfig(AuthConfig{AccessToken: "session"})
+	os.MkdirAll(filepath.Dir(authConfigPath), 0755)
+	os.WriteFile(authConfigPath, []byte(`{"accessToken":"legacy"}`), 0644)
+	t.Setenv("CONTINUE_API_KEY", "api-key")
+
+	// A project config points the default registry somewhere else
+	viper.Set("registry_url", "https://evil.example.com")
+
+	if config := LoadAuthConfigForRegistry("https://evil.example.com"); config.AccessToken != "" {
+		t.Errorf("Expected no credentials for a project default registry, got %q", config.AccessToken)
+	}
+	if config := LoadAuthConfigForRegistry("https://registry.example.com"); config.AccessToken != "api-key" {
+		t.Errorf("Expected the API key for the user's default registry, got %q", config.AccessToken)
+	}
+
+	t.Setenv("CONTINUE_API_KEY", "")
+	if config := LoadAuthConfig(); config.AccessToken != "session" {
+		t.Errorf("Expected the saved session for the user's default registry, got %q", config.AccessToken)
+	}
+}
+
+func TestRefreshTokenForScopedRegistry(t *testing.T) {
+	useTempCredentials(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"accessToken":  "acme-fresh",
+			"refreshToken": "acme-refresh-2",
+			"user":         map[string]string{"id": "user_2", "email": "dev@acme.dev"},
+		})
+	}))
+	defer server.Close()
+	viper.Set("api_base", server.URL)
+
+	SaveAuthConfig(AuthConfig{AccessToken: "default-token", RefreshToken: "default-refresh"})
+	SaveAuthConfigForRegistry("https://rules.acme.dev", AuthConfig{AccessToken: "acme-stale", RefreshToken: "acme-refresh"})
+
+	if _, err := RefreshTokenForRegistry("https://rules.acme.dev/", "acme-refresh"); err != nil {
+		t.Fatalf("RefreshTokenForRegistry failed: %v", err)
+	}
+
+	if config := LoadAuthConfigForRegistry("https://rules.acme.dev"); config.AccessToken != "acme-fresh" || config.RefreshToken != "acme-refresh-2" {
+		t.Errorf("Expected the scoped registry's session to be refreshed, got %+v", config)
+	}
+	if config := LoadAuthConfig(); config.AccessToken != "default-token" || config.RefreshToken != "default-refresh" {
+		t.Errorf("Expected the default session to stay unchanged, got %+v", config)
+	}
+}
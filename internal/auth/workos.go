@@ -6,11 +6,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -28,13 +28,15 @@ type AuthConfig struct {
 	ExpiresAt    int64  `json:"expiresAt,omitempty"`
 }
 
-// Config constants
+// Locations where earlier versions saved credentials. They are still read
+// for the default profile, see loadProfileCredentials.
 var (
 	homedir, _     = os.UserHomeDir()
 	authConfigPath = filepath.Join(homedir, ".continue", "auth.json")
 )
 
-// LoadAuthConfig loads the authentication configuration from disk
+// LoadAuthConfig loads the authentication configuration for the default
+// registry from the active profile
 func LoadAuthConfig() AuthConfig {
 	// If CONTINUE_API_KEY environment variable exists, use that instead
 	if apiKey := os.Getenv("CONTINUE_API_KEY"); apiKey != "" {
@@ -43,43 +45,87 @@ func LoadAuthConfig() AuthConfig {
 		}
 	}
 
-	// Check if auth config file exists
-	data, err := ioutil.ReadFile(authConfigPath)
-	if err != nil {
-		return AuthConfig{}
-	}
+	config, _ := loadProfileCredentials("")
+	return config
+}
+
+// defaultRegistryURL is the registry that the default credentials belong to,
+// see SetDefaultRegistry
+var defaultRegistryURL string
+
+// SetDefaultRegistry sets the registry that the default credentials (the
+// Continue login, CONTINUE_API_KEY and credentials saved by earlier
+// versions) belong to. It should be the default registry from the user's
+// own config, so that a project config pointing the default registry
+// elsewhere doesn't receive them. Until it is set, the registry_url setting
+// is used.
+func SetDefaultRegistry(registryURL string) {
+	defaultRegistryURL = registryURL
+}
+
+// isDefaultRegistry reports whether registryURL is the default registry,
+// whose credentials come from the Continue login flow
+func isDefaultRegistry(registryURL string) bool {
+	return registryURL == "" || strings.TrimRight(registryURL, "/") == registryKey("")
+}
+
+// registryAuthConfigPath returns where earlier versions saved credentials for
+// a non-default registry
+func registryAuthConfigPath(registryURL string) string {
+	name := strings.TrimRight(registryURL, "/")
+	name = strings.TrimPrefix(name, "https://")
+	name = strings.TrimPrefix(name, "http://")
+	name = strings.NewReplacer("/", "_", ":", "_").Replace(name)
+	return filepath.Join(homedir, ".continue", "registries", name+".json")
+}
 
-	var config AuthConfig
-	if err := json.Unmarshal(data, &config); err != nil {
-		fmt.Printf("Error loading auth config: %v\n", err)
-		return AuthConfig{}
+// LoadAuthConfigForRegistry loads the credentials saved for the given registry
+// in the active profile. The default registry uses the same credentials as
+// LoadAuthConfig.
+func LoadAuthConfigForRegistry(registryURL string) AuthConfig {
+	if isDefaultRegistry(registryURL) {
+		return LoadAuthConfig()
 	}
 
+	config, _ := loadProfileCredentials(registryURL)
 	return config
 }
 
-// SaveAuthConfig saves the authentication configuration to disk
-func SaveAuthConfig(config AuthConfig) {
+// SaveAuthConfigForRegistry saves credentials for the given registry in the
+// active profile
+func SaveAuthConfigForRegistry(registryURL string, config AuthConfig) error {
 	// If using CONTINUE_API_KEY environment variable, don't save anything
-	if os.Getenv("CONTINUE_API_KEY") != "" {
-		return
+	if isDefaultRegistry(registryURL) && os.Getenv("CONTINUE_API_KEY") != "" {
+		return nil
 	}
 
-	// Make sure the directory exists
-	dir := filepath.Dir(authConfigPath)
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		os.MkdirAll(dir, 0755)
+	return saveProfileCredentials(registryURL, config)
+}
+
+// LogoutFromRegistry removes the credentials saved for the given registry
+// from the active profile
+func LogoutFromRegistry(registryURL string) {
+	if isDefaultRegistry(registryURL) {
+		Logout()
+		return
 	}
 
-	// Marshal the config to JSON
-	data, err := json.MarshalIndent(config, "", "  ")
+	removed, err := removeProfileCredentials(registryURL)
 	if err != nil {
-		fmt.Printf("Error saving auth config: %v\n", err)
+		color.Red("Error removing credentials: %v", err)
 		return
 	}
+	if removed {
+		color.Green("Successfully logged out from %s", registryURL)
+	} else {
+		color.Yellow("No active session found for %s", registryURL)
+	}
+}
 
-	// Write to file
-	if err := ioutil.WriteFile(authConfigPath, data, 0644); err != nil {
+// SaveAuthConfig saves the authentication configuration for the default
+// registry in the active profile
+func SaveAuthConfig(config AuthConfig) {
+	if err := SaveAuthConfigForRegistry("", config); err != nil {
 		fmt.Printf("Error saving auth config: %v\n", err)
 	}
 }
@@ -118,24 +164,40 @@ func Prompt(question string) (string, error) {
 	return answer, err
 }
 
-// GetAuthUrlForTokenPage returns the auth URL for the token page
-func GetAuthUrlForTokenPage() string {
-	baseURL := "https://api.workos.com/user_management/authorize"
+// GetAuthUrlForTokenPage returns the sign-in URL. With an empty redirectURI
+// the browser is sent to the token page to copy a token from; otherwise it is
+// redirected to redirectURI with an authorization code. codeChallenge is the
+// PKCE challenge for the code, if any.
+func GetAuthUrlForTokenPage(redirectURI, state, codeChallenge string) string {
 	params := url.Values{}
 	params.Add("response_type", "code")
 	params.Add("client_id", viper.GetString("workos_client_id"))
 
-	redirectPath := "tokens/callback/rules"
-	params.Add("redirect_uri", fmt.Sprintf("%s/%s", viper.GetString("app_url"), redirectPath))
+	if redirectURI == "" {
+		redirectPath := "tokens/callback/rules"
+		redirectURI = fmt.Sprintf("%s/%s", viper.GetString("app_url"), redirectPath)
+	}
+	params.Add("redirect_uri", redirectURI)
 
-	params.Add("state", uuid.New().String())
+	params.Add("state", state)
 	params.Add("provider", "authkit")
+	if codeChallenge != "" {
+		params.Add("code_challenge", codeChallenge)
+		params.Add("code_challenge_method", "S256")
+	}
 
-	return fmt.Sprintf("%s?%s", baseURL, params.Encode())
+	return fmt.Sprintf("%s?%s", workosURL("/user_management/authorize"), params.Encode())
 }
 
-// RefreshToken refreshes the access token using a refresh token
+// RefreshToken refreshes the access token using a refresh token and saves
+// the new session for the default registry
 func RefreshToken(refreshToken string) (AuthConfig, error) {
+	return RefreshTokenForRegistry("", refreshToken)
+}
+
+// RefreshTokenForRegistry refreshes the access token using a refresh token
+// and saves the new session for registryURL in the active profile
+func RefreshTokenForRegistry(registryURL, refreshToken string) (AuthConfig, error) {
 	type refreshRequest struct {
 		RefreshToken string `json:"refreshToken"`
 	}
@@ -189,12 +251,16 @@ func RefreshToken(refreshToken string) (AuthConfig, error) {
 	}
 
 	// Save the config
-	SaveAuthConfig(authConfig)
+	if err := SaveAuthConfigForRegistry(registryURL, authConfig); err != nil {
+		fmt.Printf("Error saving auth config: %v\n", err)
+	}
 
 	return authConfig, nil
 }
 
-// Login authenticates using the Continue web flow
+// Login authenticates using the Continue web flow. The browser is redirected
+// back to a local callback server, falling back to pasting a token when that
+// server can't be started.
 func Login() (AuthConfig, error) {
 	// If CONTINUE_API_KEY environment variable exists, use that instead
 	if apiKey := os.Getenv("CONTINUE_API_KEY"); apiKey != "" {
@@ -204,10 +270,65 @@ func Login() (AuthConfig, error) {
 		}, nil
 	}
 
+	state := uuid.New().String()
+	server, err := StartLoopbackServer(state)
+	if err != nil {
+		color.Yellow("%v; paste a token instead", err)
+		return LoginWithPastedToken()
+	}
+	defer server.Close()
+
+	codeVerifier, codeChallenge, err := newPKCE()
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
+	color.Cyan("\nStarting authentication with Continue...")
+
+	// Get auth URL
+	authURL := GetAuthUrlForTokenPage(server.RedirectURI(), state, codeChallenge)
+	color.Green("Opening browser to sign in at: %s", authURL)
+	if err := browser.OpenURL(authURL); err != nil {
+		fmt.Printf("Failed to open browser: %v\n", err)
+		fmt.Printf("Please manually open: %s\n", authURL)
+	}
+
+	color.Yellow("\nWaiting for you to sign in in your browser...")
+
+	code, err := server.Wait(loopbackTimeout)
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
+	color.Cyan("Verifying sign-in...")
+
+	authConfig, err := ExchangeCode(code, codeVerifier, server.RedirectURI())
+	if err != nil {
+		return AuthConfig{}, errors.New("authentication failed: " + err.Error())
+	}
+
+	SaveAuthConfig(authConfig)
+
+	color.Green("\nAuthentication successful!")
+
+	return authConfig, nil
+}
+
+// LoginWithPastedToken authenticates by sending the user to the token page
+// and asking them to paste the token it shows
+func LoginWithPastedToken() (AuthConfig, error) {
+	// If CONTINUE_API_KEY environment variable exists, use that instead
+	if apiKey := os.Getenv("CONTINUE_API_KEY"); apiKey != "" {
+		color.Green("Using CONTINUE_API_KEY from environment variables")
+		return AuthConfig{
+			AccessToken: apiKey,
+		}, nil
+	}
+
 	color.Cyan("\nStarting authentication with Continue...")
 
 	// Get auth URL
-	authURL := GetAuthUrlForTokenPage()
+	authURL := GetAuthUrlForTokenPage("", uuid.New().String(), "")
 	color.Green("Opening browser to sign in at: %s", authURL)
 	if err := browser.OpenURL(authURL); err != nil {
 		fmt.Printf("Failed to open browser: %v\n", err)
@@ -235,15 +356,20 @@ func Login() (AuthConfig, error) {
 	return response, nil
 }
 
-// Logout logs the user out by clearing saved credentials
+// Logout logs the user out of the default registry by clearing the
+// credentials saved in the active profile
 func Logout() {
 	if os.Getenv("CONTINUE_API_KEY") != "" {
 		color.Yellow("Using CONTINUE_API_KEY from environment variables, nothing to log out")
 		return
 	}
 
-	if _, err := os.Stat(authConfigPath); err == nil {
-		os.Remove(authConfigPath)
+	removed, err := removeProfileCredentials("")
+	if err != nil {
+		color.Red("Error removing credentials: %v", err)
+		return
+	}
+	if removed {
 		color.Green("Successfully logged out")
 	} else {
 		color.Yellow("No active session found")
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,66 +11,275 @@ import (
 
 // Config holds configuration for the rules CLI
 type Config struct {
-	RegistryURL   string
-	DefaultFormat string
-	Username      string
-	Email         string
-	Formats       []string
+	RegistryURL string
+	// UserRegistryURL is the default registry according to the user's own
+	// config and environment, leaving out the project config. The default
+	// credentials belong to it and are never sent to a default registry
+	// set by a project.
+	UserRegistryURL string
+	DefaultFormat   string
+	Username        string
+	Email           string
+	// Targets are the formats rendered by 'rules render' without arguments
+	// and re-rendered after rules are added or removed
+	Targets []string
+	// BlockPosition is where rendering adds its managed block to single
+	// files that don't have one yet: "top" or "bottom"
+	BlockPosition string
 	AppURL        string
+	GitHubAPIURL  string
+	// Registries maps owner scopes (e.g. "@acme") to registry URLs.
+	// The "default" entry, if present, overrides RegistryURL.
+	Registries map[string]string
+	// TrustedKeys are the public keys ("ed25519:<base64>") whose package
+	// signatures are accepted, from the user config or the environment, and
+	// from the project config if trust_project_keys is set
+	TrustedKeys []string
+	// SignaturePolicy is "off", "warn" or "require"
+	SignaturePolicy string
+	// Profile is the credentials profile selected by the environment or the
+	// config, or empty to use the one chosen with 'rules auth switch'
+	Profile string
+	// Warnings describe project config settings that were ignored
+	Warnings []string
 }
 
-// Initialize sets up the configuration from environment variables and Viper
-func Initialize() (*Config, error) {
-	// Set up Viper
-	viper.SetConfigName("rules-cli")
-	viper.SetConfigType("yaml")
+// BlockPosition is where rendering adds its managed block to a single file
+// that doesn't have one yet, the values of the block_position setting
+type BlockPosition string
+
+const (
+	// BlockTop adds the block before the content of the file
+	BlockTop BlockPosition = "top"
+	// BlockBottom adds the block after the content of the file
+	BlockBottom BlockPosition = "bottom"
+
+	// DefaultBlockPosition is used when no block position is configured
+	DefaultBlockPosition = BlockBottom
+)
+
+// DefaultRegistryScope is the key in Registries used for unscoped packages
+const DefaultRegistryScope = "default"
+
+// Defaults for the Continue services
+const (
+	defaultAPIBase = "https://api.continue.dev"
+	// defaultAPIBase = "http://localhost:3001"
+	defaultClientID = "client_01J0FW6XN8N2XJAECF7NE0Y65J"
+	// defaultClientID = "client_01J0FW6XCPMJMQ3CG51RB4HBZQ"
+	defaultAppURL = "https://hub.continue.dev"
+	// defaultAppURL = "http://localhost:3000"
+)
 
-	// Look for config in the user's home directory
+// configFile is the global config file given with --config
+var configFile string
+
+// SetConfigFile makes path the global config file instead of
+// ~/.rules-cli/rules-cli.yaml
+func SetConfigFile(path string) {
+	configFile = path
+}
+
+// userConfigDir returns the directory with the user's settings, ~/.rules-cli
+func userConfigDir() string {
 	home, err := os.UserHomeDir()
-	if err == nil {
-		viper.AddConfigPath(filepath.Join(home, ".rules-cli"))
+	if err != nil {
+		return ".rules-cli"
 	}
-	viper.AddConfigPath(".")
+	return filepath.Join(home, ".rules-cli")
+}
+
+// GlobalConfigPath returns the path of the global (per-user) config file
+func GlobalConfigPath() string {
+	if configFile != "" {
+		return configFile
+	}
+	return filepath.Join(userConfigDir(), "rules-cli.yaml")
+}
 
+// UserFormatsDir returns the directory with the user's format definitions
+func UserFormatsDir() string {
+	return filepath.Join(userConfigDir(), FormatsDirName)
+}
+
+// Initialize sets up the configuration. Settings come from, in order of
+// precedence: RULES_* environment variables, the project config file (see
+// ProjectConfigPath), the global config file and the defaults. Command line
+// flags are applied on top by the commands.
+func Initialize() (*Config, error) {
 	// Set default values
-	var default_api_base = "https://api.continue.dev"
-	// var defaultApiBase = "http://localhost:3001"
-	var client_id = "client_01J0FW6XN8N2XJAECF7NE0Y65J"
-	// var client_id = "client_01J0FW6XCPMJMQ3CG51RB4HBZQ";
-	var app_url = "https://hub.continue.dev"
-	// var app_url = "http://localhost:3000"
-
-	viper.SetDefault("registry_url", default_api_base)
-	viper.SetDefault("default_format", "default")
-	viper.SetDefault("username", "")
-	viper.SetDefault("email", "")
-	viper.SetDefault("formats", []string{"default"})
-
-	// Set default values for auth-related configurations
-	viper.SetDefault("workos_client_id", client_id)
-	viper.SetDefault("app_url", app_url)
-	viper.SetDefault("api_base", default_api_base)
+	for _, setting := range Settings {
+		viper.SetDefault(setting.Key, setting.Default)
+	}
 
 	// Bind environment variables
 	viper.AutomaticEnv()
 	viper.SetEnvPrefix("RULES")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
-	// Read the config file
-	_ = viper.ReadInConfig() // Ignore error if config file is not found
+	// Read the global config file, if there is one
+	viper.SetConfigType("yaml")
+	globalPath := GlobalConfigPath()
+	if _, err := os.Stat(globalPath); err == nil {
+		viper.SetConfigFile(globalPath)
+		if err := viper.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", globalPath, err)
+		}
+	}
+
+	userRegistryURL := defaultRegistryURL()
+
+	// The project config is layered over the global one, except for the
+	// settings a project isn't trusted to change
+	project, err := readProjectConfig()
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	if project != nil {
+		warnings = filterProjectConfig(project)
+		if err := viper.MergeConfigMap(project); err != nil {
+			return nil, fmt.Errorf("failed to read project config: %w", err)
+		}
+	}
+
+	// Keep registry_url in step so everything reading it directly agrees
+	registryURL := defaultRegistryURL()
+	if registryURL != viper.GetString("registry_url") {
+		viper.Set("registry_url", registryURL)
+	}
+	if strings.TrimRight(registryURL, "/") != strings.TrimRight(userRegistryURL, "/") {
+		warnings = append(warnings, fmt.Sprintf("the project config sets the default registry to %s; credentials for %s aren't sent to it", registryURL, userRegistryURL))
+	}
+	registries := viper.GetStringMapString("registries")
 
 	config := Config{
-		RegistryURL:   viper.GetString("registry_url"),
-		DefaultFormat: viper.GetString("default_format"),
-		Username:      viper.GetString("username"),
-		Email:         viper.GetString("email"),
-		Formats:       viper.GetStringSlice("formats"),
-		AppURL:        viper.GetString("app_url"),
+		RegistryURL:     registryURL,
+		UserRegistryURL: userRegistryURL,
+		DefaultFormat:   viper.GetString("default_format"),
+		Username:        viper.GetString("username"),
+		Email:           viper.GetString("email"),
+		Targets:         viper.GetStringSlice("targets"),
+		BlockPosition:   viper.GetString("block_position"),
+		AppURL:          viper.GetString("app_url"),
+		GitHubAPIURL:    viper.GetString("github_api_url"),
+		Registries:      registries,
+
+		TrustedKeys:     viper.GetStringSlice("trusted_keys"),
+		SignaturePolicy: viper.GetString("signature_policy"),
+		Profile:         viper.GetString("profile"),
+		Warnings:        warnings,
 	}
 
 	return &config, nil
 }
 
+// ParseBlockPosition normalizes a block_position setting to BlockTop or
+// BlockBottom
+func ParseBlockPosition(value string) (BlockPosition, error) {
+	switch position := BlockPosition(strings.ToLower(strings.TrimSpace(value))); position {
+	case "":
+		return DefaultBlockPosition, nil
+	case BlockTop, BlockBottom:
+		return position, nil
+	}
+	return "", fmt.Errorf("unknown block position %q; use top or bottom", value)
+}
+
+// RegistryURLForOwner returns the URL of the registry that serves packages
+// owned by ownerSlug. Owners with a scoped entry ("@owner") use that registry,
+// everything else uses the default registry.
+func (c *Config) RegistryURLForOwner(ownerSlug string) string {
+	scope := "@" + strings.ToLower(strings.TrimPrefix(ownerSlug, "@"))
+	if url, ok := c.Registries[scope]; ok && url != "" {
+		return url
+	}
+	return c.RegistryURL
+}
+
+// IsUserRegistry reports whether registryURL is the user's default registry,
+// UserRegistryURL, ignoring a trailing slash
+func (c *Config) IsUserRegistry(registryURL string) bool {
+	return strings.TrimRight(registryURL, "/") == strings.TrimRight(c.UserRegistryURL, "/")
+}
+
+// ResolveRegistry turns a registry argument into a registry URL.
+// The argument may be empty (default registry), a scope such as "@acme",
+// or a full URL.
+func (c *Config) ResolveRegistry(registry string) (string, error) {
+	if registry == "" || registry == DefaultRegistryScope {
+		return c.RegistryURL, nil
+	}
+
+	if strings.HasPrefix(registry, "@") {
+		url, ok := c.Registries[strings.ToLower(registry)]
+		if !ok || url == "" {
+			return "", fmt.Errorf("no registry configured for scope %s", registry)
+		}
+		return url, nil
+	}
+
+	if !strings.HasPrefix(registry, "http://") && !strings.HasPrefix(registry, "https://") {
+		return "", fmt.Errorf("registry must be a scope (e.g. @acme) or a URL, got %q", registry)
+	}
+
+	return strings.TrimRight(registry, "/"), nil
+}
+
+// defaultRegistryURL returns the default registry from the settings read so
+// far. A default entry in registries takes the place of registry_url so that
+// everything else agrees on which registry is the default. An explicit
+// RULES_REGISTRY_URL still wins.
+func defaultRegistryURL() string {
+	registries := viper.GetStringMapString("registries")
+	if defaultURL, ok := registries[DefaultRegistryScope]; ok && defaultURL != "" && os.Getenv("RULES_REGISTRY_URL") == "" {
+		return defaultURL
+	}
+	return viper.GetString("registry_url")
+}
+
+// filterProjectConfig removes the settings the project config isn't allowed
+// to change from its settings, and returns a warning for each. Values are
+// compared with what the global config, environment and defaults give.
+func filterProjectConfig(project map[string]interface{}) []string {
+	var warnings []string
+	for _, setting := range Settings {
+		value, ok := project[setting.Key]
+		if !ok {
+			continue
+		}
+		if reason := ignoredProjectValue(setting, value, viper.Get(setting.Key), viper.GetBool("trust_project_keys")); reason != "" {
+			delete(project, setting.Key)
+			warnings = append(warnings, fmt.Sprintf("ignoring %s from the project config: %s", setting.Key, reason))
+		}
+	}
+
+	// Trusted keys from the project are added to the user's rather than
+	// replacing them
+	if keys, ok := project["trusted_keys"]; ok && os.Getenv("RULES_TRUSTED_KEYS") == "" {
+		project["trusted_keys"] = appendUnique(viper.GetStringSlice("trusted_keys"), toStrings(keys)...)
+	}
+	return warnings
+}
+
+// readProjectConfig reads the settings in the project config file, if there
+// is one and it isn't also the global config file
+func readProjectConfig() (map[string]interface{}, error) {
+	_, path := findProject()
+	if path == "" {
+		return nil, nil
+	}
+	if global, err := filepath.Abs(GlobalConfigPath()); err == nil && global == path {
+		return nil, nil
+	}
+
+	file, err := LoadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project config: %w", err)
+	}
+	return file.Settings()
+}
+
 // LoadConfig loads the configuration
 func LoadConfig() (*Config, error) {
 	return Initialize()
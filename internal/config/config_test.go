@@ -2,7 +2,12 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func TestInitialize(t *testing.T) {
@@ -86,3 +91,301 @@ func TestInitializeWithEnvironmentVariables(t *testing.T) {
 		t.Errorf("Expected Email to be set by env var, got %s", cfg.Email)
 	}
 }
+
+func TestRegistryURLForOwner(t *testing.T) {
+	cfg := &Config{
+		RegistryURL: "https://api.continue.dev",
+		Registries: map[string]string{
+			"@acme": "https://rules.acme.internal",
+		},
+	}
+
+	tests := []struct {
+		owner    string
+		expected string
+	}{
+		{"acme", "https://rules.acme.internal"},
+		{"ACME", "https://rules.acme.internal"},
+		{"starter", "https://api.continue.dev"},
+		{"", "https://api.continue.dev"},
+	}
+
+	for _, tt := range tests {
+		if got := cfg.RegistryURLForOwner(tt.owner); got != tt.expected {
+			t.Errorf("RegistryURLForOwner(%q) = %s, expected %s", tt.owner, got, tt.expected)
+		}
+	}
+}
+
+func TestResolveRegistry(t *testing.T) {
+	cfg := &Config{
+		RegistryURL: "https://api.continue.dev",
+		Registries: map[string]string{
+			"@acme": "https://rules.acme.internal",
+		},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+		hasError bool
+	}{
+		{"", "https://api.continue.dev", false},
+		{"default", "https://api.continue.dev", false},
+		{"@acme", "https://rules.acme.internal", false},
+		{"@unknown", "", true},
+		{"http://localhost:8080/", "http://localhost:8080", false},
+		{"not-a-registry", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := cfg.ResolveRegistry(tt.input)
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("ResolveRegistry(%q) expected error, got %s", tt.input, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolveRegistry(%q) unexpected error: %v", tt.input, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ResolveRegistry(%q) = %s, expected %s", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestInitializeLayersProjectTrustSettings(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".rules-cli"), 0755)
+	os.WriteFile(filepath.Join(home, ".rules-cli", "rules-cli.yaml"), []byte("trusted_keys:\n  - ed25519:user\nsignature_policy: warn\n"), 0644)
+
+	project := t.TempDir()
+	t.Chdir(project)
+	os.WriteFile(filepath.Join(project, "rules-cli.yaml"), []byte("trusted_keys:\n  - ed25519:project\n  - ed25519:user\nsignature_policy: require\n"), 0644)
+
+	cfg, err := Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+
+	// Only the user's keys are trusted
+	expectedKeys := []string{"ed25519:user"}
+	if !reflect.DeepEqual(cfg.TrustedKeys, expectedKeys) {
+		t.Errorf("Expected trusted keys %v, got %v", expectedKeys, cfg.TrustedKeys)
+	}
+	if cfg.SignaturePolicy != "require" {
+		t.Errorf("Expected stricter project signature policy to win, got %s", cfg.SignaturePolicy)
+	}
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "trust_project_keys") {
+		t.Errorf("Expected a warning about the project's trusted keys, got %v", cfg.Warnings)
+	}
+
+	// Once the user opts in, the project's keys are added to theirs
+	viper.Reset()
+	os.WriteFile(filepath.Join(home, ".rules-cli", "rules-cli.yaml"), []byte("trusted_keys:\n  - ed25519:user\nsignature_policy: warn\ntrust_project_keys: true\n"), 0644)
+	cfg, err = Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	expectedKeys = []string{"ed25519:user", "ed25519:project"}
+	if !reflect.DeepEqual(cfg.TrustedKeys, expectedKeys) {
+		t.Errorf("Expected trusted keys %v, got %v", expectedKeys, cfg.TrustedKeys)
+	}
+	if len(cfg.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", cfg.Warnings)
+	}
+
+	// A project can't weaken the user's policy, or opt in to its own keys
+	viper.Reset()
+	os.WriteFile(filepath.Join(home, ".rules-cli", "rules-cli.yaml"), []byte("trusted_keys:\n  - ed25519:user\nsignature_policy: warn\n"), 0644)
+	os.WriteFile(filepath.Join(project, "rules-cli.yaml"), []byte("signature_policy: off\ntrust_project_keys: true\ntrusted_keys:\n  - ed25519:project\n"), 0644)
+	cfg, err = Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	if cfg.SignaturePolicy != "warn" {
+		t.Errorf("Expected user signature policy to win over a weaker one, got %s", cfg.SignaturePolicy)
+	}
+	if !reflect.DeepEqual(cfg.TrustedKeys, []string{"ed25519:user"}) {
+		t.Errorf("Expected only the user's keys to be trusted, got %v", cfg.TrustedKeys)
+	}
+	if len(cfg.Warnings) != 3 || !strings.Contains(strings.Join(cfg.Warnings, "\n"), "signature_policy") {
+		t.Errorf("Expected warnings about the project's trust settings, got %v", cfg.Warnings)
+	}
+}
+
+func TestInitializeIgnoresProjectEndpoints(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	t.Setenv("HOME", t.TempDir())
+	project := t.TempDir()
+	t.Chdir(project)
+	os.WriteFile(filepath.Join(project, "rules-cli.yaml"), []byte("api_base: https://evil.example.com\nworkos_api_url: https://evil.example.com\nregistries:\n  default: https://rules.example.com\n"), 0644)
+
+	cfg, err := Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+
+	if apiBase := viper.GetString("api_base"); apiBase != defaultAPIBase {
+		t.Errorf("Expected project api_base to be ignored, got %s", apiBase)
+	}
+	if cfg.RegistryURL != "https://rules.example.com" || cfg.UserRegistryURL != defaultAPIBase {
+		t.Errorf("Expected project default registry %s with user registry %s, got %s and %s", "https://rules.example.com", defaultAPIBase, cfg.RegistryURL, cfg.UserRegistryURL)
+	}
+	if !cfg.IsUserRegistry(defaultAPIBase+"/") || cfg.IsUserRegistry(cfg.RegistryURL) {
+		t.Errorf("Expected only %s to be the user's registry", defaultAPIBase)
+	}
+
+	warnings := strings.Join(cfg.Warnings, "\n")
+	for _, expected := range []string{"ignoring api_base", "ignoring workos_api_url", "sets the default registry to https://rules.example.com"} {
+		if !strings.Contains(warnings, expected) {
+			t.Errorf("Expected a warning containing %q, got:\n%s", expected, warnings)
+		}
+	}
+}
+
+func TestInitializeWithConfigFlag(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() { SetConfigFile("") })
+
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "custom.yaml")
+	os.WriteFile(path, []byte("default_format: cursor\n"), 0644)
+	SetConfigFile(path)
+
+	if GlobalConfigPath() != path {
+		t.Errorf("Expected global config path %s, got %s", path, GlobalConfigPath())
+	}
+	cfg, err := Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	if cfg.DefaultFormat != "cursor" {
+		t.Errorf("Expected default format from --config file, got %s", cfg.DefaultFormat)
+	}
+}
+
+func TestResolveSources(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	os.MkdirAll(filepath.Join(home, ".rules-cli"), 0755)
+	os.WriteFile(filepath.Join(home, ".rules-cli", "rules-cli.yaml"), []byte("default_format: cursor\nusername: alice\ntrusted_keys:\n  - ed25519:user\nregistries:\n  \"@acme\": https://rules.acme.dev\n"), 0644)
+
+	project := t.TempDir()
+	t.Chdir(project)
+	os.WriteFile(filepath.Join(project, "rules-cli.yaml"), []byte("default_format: continue\ntrusted_keys:\n  - ed25519:project\nregistries:\n  default: https://rules.example.com\n"), 0644)
+
+	t.Setenv("RULES_USERNAME", "bob")
+
+	tests := []struct {
+		key     string
+		value   interface{}
+		sources []Source
+	}{
+		{"default_format", "continue", []Source{SourceProject}},
+		{"username", "bob", []Source{SourceEnv}},
+		{"email", "", []Source{SourceDefault}},
+		{"trusted_keys", []interface{}{"ed25519:user"}, []Source{SourceGlobal}},
+		{"registries.@acme", "https://rules.acme.dev", []Source{SourceGlobal}},
+		{"registry_url", "https://rules.example.com", []Source{SourceProject}},
+	}
+
+	for _, tt := range tests {
+		resolved, err := Resolve(tt.key)
+		if err != nil {
+			t.Fatalf("Resolve(%q) failed: %v", tt.key, err)
+		}
+		if len(resolved) != 1 {
+			t.Fatalf("Resolve(%q) returned %d settings, expected 1", tt.key, len(resolved))
+		}
+		if !reflect.DeepEqual(resolved[0].Value, tt.value) {
+			t.Errorf("Resolve(%q) value = %#v, expected %#v", tt.key, resolved[0].Value, tt.value)
+		}
+		if !reflect.DeepEqual(resolved[0].Sources, tt.sources) {
+			t.Errorf("Resolve(%q) sources = %v, expected %v", tt.key, resolved[0].Sources, tt.sources)
+		}
+	}
+
+	// Trusting project keys combines both files
+	t.Setenv("RULES_TRUST_PROJECT_KEYS", "true")
+	resolved, err := Resolve("trusted_keys")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if !reflect.DeepEqual(resolved[0].Value, []string{"ed25519:user", "ed25519:project"}) || !reflect.DeepEqual(resolved[0].Sources, []Source{SourceGlobal, SourceProject}) {
+		t.Errorf("Expected the user and project keys, got %#v from %v", resolved[0].Value, resolved[0].Sources)
+	}
+
+	// A relative --config naming the project file isn't read twice
+	SetConfigFile("rules-cli.yaml")
+	t.Cleanup(func() { SetConfigFile("") })
+	resolved, err = Resolve("default_format")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if !reflect.DeepEqual(resolved[0].Sources, []Source{SourceGlobal}) {
+		t.Errorf("Expected the config file to be read as the global config, got %v", resolved[0].Sources)
+	}
+}
+
+func TestParseBlockPosition(t *testing.T) {
+	for value, expected := range map[string]BlockPosition{"": BlockBottom, "top": BlockTop, " Bottom ": BlockBottom} {
+		if position, err := ParseBlockPosition(value); err != nil || position != expected {
+			t.Errorf("ParseBlockPosition(%q) = %q, %v, expected %q", value, position, err, expected)
+		}
+	}
+	if _, err := ParseBlockPosition("middle"); err == nil {
+		t.Error("Expected an unknown position to fail")
+	}
+}
+
+func TestSettingParseValue(t *testing.T) {
+	tests := []struct {
+		key     string
+		values  []string
+		wantErr bool
+	}{
+		{"default_format", []string{"cursor"}, false},
+		{"default_format", []string{"cursor", "continue"}, true},
+		{"targets", []string{"cursor", "continue"}, false},
+		{"targets", []string{"cursor", "default"}, true},
+		{"registries.@acme", []string{"https://rules.acme.dev"}, false},
+		{"registries.@acme", []string{"rules.acme.dev"}, true},
+		{"registries", []string{"https://rules.acme.dev"}, true},
+		{"signature_policy", []string{"sometimes"}, true},
+		{"block_position", []string{"top"}, false},
+		{"block_position", []string{"middle"}, true},
+		{"trusted_keys", []string{"not-a-key"}, true},
+	}
+
+	for _, tt := range tests {
+		setting, name, err := LookupSetting(tt.key)
+		if err != nil {
+			t.Fatalf("LookupSetting(%q) failed: %v", tt.key, err)
+		}
+		_, err = setting.ParseValue(name, tt.values)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseValue(%q, %v) error = %v, wantErr %v", tt.key, tt.values, err, tt.wantErr)
+		}
+	}
+
+	for _, key := range []string{"nope", "default_format.x", "registries."} {
+		if _, _, err := LookupSetting(key); err == nil {
+			t.Errorf("Expected LookupSetting(%q) to fail", key)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a YAML config file that is edited in place, keeping comments and
+// the order of keys
+type File struct {
+	Path string
+	doc  *yaml.Node
+	// inRulesJSON is set for the config block of a rules.json, which is read
+	// but never written
+	inRulesJSON bool
+}
+
+// LoadFile reads a config file. A missing file is treated as empty. For a
+// rules.json, the settings are read from its "config" block.
+func LoadFile(path string) (*File, error) {
+	f := &File{Path: path, doc: &yaml.Node{Kind: yaml.DocumentNode}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := yaml.Unmarshal(data, f.doc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if root := f.root(false); root == nil {
+			return nil, fmt.Errorf("%s must contain a mapping of settings", path)
+		}
+	}
+
+	if filepath.Base(path) == rulesJSONFile {
+		return f.configBlock()
+	}
+	return f, nil
+}
+
+// configBlock narrows a loaded rules.json down to its config block
+func (f *File) configBlock() (*File, error) {
+	block := &File{Path: f.Path, doc: &yaml.Node{Kind: yaml.DocumentNode}, inRulesJSON: true}
+	root := f.root(false)
+	if root == nil {
+		return block, nil
+	}
+
+	if config := lookup(root, "config"); config != nil {
+		if config.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("config in %s must be a mapping of settings", f.Path)
+		}
+		block.doc.Content = []*yaml.Node{config}
+	}
+	return block, nil
+}
+
+// root returns the top-level mapping, creating it if asked to
+func (f *File) root(create bool) *yaml.Node {
+	if len(f.doc.Content) == 0 {
+		if !create {
+			return nil
+		}
+		f.doc.Content = []*yaml.Node{{Kind: yaml.MappingNode}}
+	}
+	root := f.doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil
+	}
+	return root
+}
+
+// lookup returns the value node for a key in a mapping node
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Get returns the value of a key, which may be "<map>.<name>" for an entry
+// of a map setting
+func (f *File) Get(key string) (interface{}, bool) {
+	node := f.root(false)
+	for _, part := range splitKey(key) {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil, false
+		}
+		node = lookup(node, part)
+	}
+	if node == nil {
+		return nil, false
+	}
+
+	var value interface{}
+	if err := node.Decode(&value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// Settings returns everything set in the file
+func (f *File) Settings() (map[string]interface{}, error) {
+	settings := map[string]interface{}{}
+	if root := f.root(false); root != nil {
+		if err := root.Decode(&settings); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f.Path, err)
+		}
+	}
+	return settings, nil
+}
+
+// Keys returns the top-level keys set in the file, in file order
+func (f *File) Keys() []string {
+	root := f.root(false)
+	if root == nil {
+		return nil
+	}
+	var keys []string
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keys = append(keys, root.Content[i].Value)
+	}
+	return keys
+}
+
+// Set sets a key to a string or a list of strings, replacing any existing
+// value in place
+func (f *File) Set(key string, value interface{}) error {
+	var valueNode yaml.Node
+	if err := valueNode.Encode(value); err != nil {
+		return fmt.Errorf("failed to encode value: %w", err)
+	}
+
+	node := f.root(true)
+	if node == nil {
+		return fmt.Errorf("%s must contain a mapping of settings", f.Path)
+	}
+
+	parts := splitKey(key)
+	for i, part := range parts {
+		last := i == len(parts)-1
+		existing := lookup(node, part)
+		if last {
+			if existing != nil {
+				// Keep comments attached to the old value
+				valueNode.HeadComment = existing.HeadComment
+				valueNode.LineComment = existing.LineComment
+				*existing = valueNode
+			} else {
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, &valueNode)
+			}
+			return nil
+		}
+
+		if existing == nil {
+			existing = &yaml.Node{Kind: yaml.MappingNode}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, existing)
+		} else if existing.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s in %s is not a mapping", part, f.Path)
+		}
+		node = existing
+	}
+	return nil
+}
+
+// Unset removes a key, reporting whether it was set. A map left empty is
+// removed too.
+func (f *File) Unset(key string) bool {
+	return unset(f.root(false), splitKey(key))
+}
+
+func unset(mapping *yaml.Node, parts []string) bool {
+	if mapping == nil || mapping.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != parts[0] {
+			continue
+		}
+		if len(parts) == 1 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+		child := mapping.Content[i+1]
+		if !unset(child, parts[1:]) {
+			return false
+		}
+		if len(child.Content) == 0 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		}
+		return true
+	}
+	return false
+}
+
+// Save writes the file, replacing it atomically so a failed write never
+// leaves a truncated config behind
+func (f *File) Save() error {
+	if f.inRulesJSON {
+		return fmt.Errorf("settings in the config block of %s can't be changed with 'rules config'; edit the file instead", f.Path)
+	}
+
+	var buf bytes.Buffer
+	if root := f.root(false); root != nil && len(root.Content) > 0 {
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(f.doc); err != nil {
+			return fmt.Errorf("failed to encode config: %w", err)
+		}
+		encoder.Close()
+	}
+
+	perm := os.FileMode(0644)
+	if info, err := os.Stat(f.Path); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(f.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(f.Path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), f.Path); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	return nil
+}
+
+// splitKey splits "<map>.<name>" keys. Only the first dot separates, so map
+// entry names (such as URLs) may contain dots.
+func splitKey(key string) []string {
+	if base, name, ok := strings.Cut(key, "."); ok {
+		return []string{base, name}
+	}
+	return []string{key}
+}
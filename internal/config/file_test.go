@@ -0,0 +1,95 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileSetKeepsComments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules-cli.yaml")
+	os.WriteFile(path, []byte("# Settings for work\ndefault_format: cursor # the editor we use\nregistries:\n  \"@acme\": https://rules.acme.dev\n"), 0600)
+
+	file, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if err := file.Set("default_format", "continue"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := file.Set("registries.@corp", "https://rules.corp.example"); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := file.Set("trusted_keys", []string{"ed25519:a", "ed25519:b"}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if err := file.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	content := string(data)
+	for _, expected := range []string{"# Settings for work", "default_format: continue # the editor we use", "'@corp': https://rules.corp.example"} {
+		if !strings.Contains(content, expected) {
+			t.Errorf("Expected %q in saved config:\n%s", expected, content)
+		}
+	}
+
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected file mode to be kept, got %o", info.Mode().Perm())
+	}
+
+	reloaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if value, _ := reloaded.Get("registries.@acme"); value != "https://rules.acme.dev" {
+		t.Errorf("Expected existing registry to be kept, got %v", value)
+	}
+	if value, _ := reloaded.Get("trusted_keys"); !reflect.DeepEqual(toStrings(value), []string{"ed25519:a", "ed25519:b"}) {
+		t.Errorf("Unexpected trusted keys %v", value)
+	}
+	if keys := reloaded.Keys(); !reflect.DeepEqual(keys, []string{"default_format", "registries", "trusted_keys"}) {
+		t.Errorf("Unexpected keys %v", keys)
+	}
+}
+
+func TestFileUnset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules-cli.yaml")
+	os.WriteFile(path, []byte("default_format: cursor\nregistries:\n  \"@acme\": https://rules.acme.dev\n"), 0644)
+
+	file, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if file.Unset("username") {
+		t.Error("Expected unsetting a missing key to report false")
+	}
+	if !file.Unset("registries.@acme") {
+		t.Error("Expected registries.@acme to be removed")
+	}
+	if _, ok := file.Get("registries"); ok {
+		t.Error("Expected the emptied registries map to be removed")
+	}
+	if !file.Unset("default_format") {
+		t.Error("Expected default_format to be removed")
+	}
+	if err := file.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("Expected an empty config, got %q", data)
+	}
+}
+
+func TestLoadFileRejectsNonMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules-cli.yaml")
+	os.WriteFile(path, []byte("- cursor\n"), 0644)
+
+	if _, err := LoadFile(path); err == nil {
+		t.Error("Expected a list at the top level to be rejected")
+	}
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Project config locations, relative to a project directory
+const (
+	// ProjectConfigDir and ProjectConfigName make up the preferred project
+	// config file, .rules/config.yaml
+	ProjectConfigDir  = ".rules"
+	ProjectConfigName = "config.yaml"
+	// FormatsDirName is the directory with format definitions, both in
+	// ~/.rules-cli and in the project's .rules
+	FormatsDirName = "formats"
+	// rulesJSONFile may hold project settings in a "config" block
+	rulesJSONFile = "rules.json"
+	// legacyProjectConfigFile is the project config used by older versions
+	legacyProjectConfigFile = "rules-cli.yaml"
+)
+
+// projectConfigCandidates returns the project config files that may exist in
+// dir, in order of preference
+func projectConfigCandidates(dir string) []string {
+	return []string{
+		filepath.Join(dir, ProjectConfigDir, ProjectConfigName),
+		filepath.Join(dir, rulesJSONFile),
+		filepath.Join(dir, legacyProjectConfigFile),
+	}
+}
+
+// findProject walks up from the current directory to the repository root
+// (the first directory containing .git) looking for a project config. It
+// returns the config file, or "" if there is none, and the project root: the
+// directory holding the config, or else the nearest directory with a
+// rules.json, the repository root, or the current directory.
+func findProject() (root, configPath string) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return ".", ""
+	}
+
+	for dir := cwd; ; {
+		for _, candidate := range projectConfigCandidates(dir) {
+			if isProjectConfig(candidate) {
+				return dir, candidate
+			}
+		}
+
+		if root == "" && fileExists(filepath.Join(dir, rulesJSONFile)) {
+			root = dir
+		}
+		if fileExists(filepath.Join(dir, ".git")) {
+			if root == "" {
+				root = dir
+			}
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	if root == "" {
+		root = cwd
+	}
+	return root, ""
+}
+
+// isProjectConfig reports whether path is a project config file. A rules.json
+// only counts if it has a config block.
+func isProjectConfig(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if filepath.Base(path) != rulesJSONFile {
+		return true
+	}
+
+	file, err := LoadFile(path)
+	return err == nil && file.root(false) != nil
+}
+
+// ProjectRoot returns the root directory of the current project
+func ProjectRoot() string {
+	root, _ := findProject()
+	return root
+}
+
+// ProjectConfigPath returns the path of the project config file. If there
+// is none yet, it is .rules/config.yaml in the project root.
+func ProjectConfigPath() string {
+	root, path := findProject()
+	if path != "" {
+		return path
+	}
+	return filepath.Join(root, ProjectConfigDir, ProjectConfigName)
+}
+
+// ProjectFormatsDir returns the directory with the project's format
+// definitions, .rules/formats in the project root
+func ProjectFormatsDir() string {
+	return filepath.Join(ProjectRoot(), ProjectConfigDir, FormatsDirName)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
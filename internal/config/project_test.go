@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// useTempProject isolates a test from the user's config and returns a
+// repository directory with a .git directory in it
+func useTempProject(t *testing.T) string {
+	t.Helper()
+
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	t.Setenv("HOME", t.TempDir())
+
+	repo := filepath.Join(t.TempDir(), "repo")
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	return repo
+}
+
+func TestProjectConfigFoundInParentDirectory(t *testing.T) {
+	repo := useTempProject(t)
+	os.MkdirAll(filepath.Join(repo, ".rules"), 0755)
+	os.WriteFile(filepath.Join(repo, ".rules", "config.yaml"), []byte("default_format: cursor\nsignature_policy: require\n"), 0644)
+
+	nested := filepath.Join(repo, "services", "api")
+	os.MkdirAll(nested, 0755)
+	t.Chdir(nested)
+
+	if path := ProjectConfigPath(); path != filepath.Join(repo, ".rules", "config.yaml") {
+		t.Errorf("Expected project config in repository root, got %s", path)
+	}
+	if root := ProjectRoot(); root != repo {
+		t.Errorf("Expected project root %s, got %s", repo, root)
+	}
+
+	t.Setenv("RULES_SIGNATURE_POLICY", "off")
+	cfg, err := Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	if cfg.DefaultFormat != "cursor" {
+		t.Errorf("Expected default format from project config, got %s", cfg.DefaultFormat)
+	}
+	if cfg.SignaturePolicy != "off" {
+		t.Errorf("Expected environment to override project config, got %s", cfg.SignaturePolicy)
+	}
+}
+
+func TestProjectConfigSearchStopsAtRepositoryRoot(t *testing.T) {
+	repo := useTempProject(t)
+	outside := filepath.Dir(repo)
+	os.MkdirAll(filepath.Join(outside, ".rules"), 0755)
+	os.WriteFile(filepath.Join(outside, ".rules", "config.yaml"), []byte("default_format: cursor\n"), 0644)
+	t.Chdir(repo)
+
+	if path := ProjectConfigPath(); path != filepath.Join(repo, ".rules", "config.yaml") {
+		t.Errorf("Expected new project config in repository root, got %s", path)
+	}
+
+	cfg, err := Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	if cfg.DefaultFormat != "default" {
+		t.Errorf("Expected config outside the repository to be ignored, got %s", cfg.DefaultFormat)
+	}
+}
+
+func TestProjectConfigInRulesJSON(t *testing.T) {
+	repo := useTempProject(t)
+	t.Chdir(repo)
+
+	// A rules.json without a config block is not a project config
+	os.WriteFile(filepath.Join(repo, "rules.json"), []byte(`{"name": "acme/rules", "version": "1.0.0", "rules": {}}`), 0644)
+	if path := ProjectConfigPath(); path != filepath.Join(repo, ".rules", "config.yaml") {
+		t.Errorf("Expected rules.json without config to be skipped, got %s", path)
+	}
+
+	os.WriteFile(filepath.Join(repo, "rules.json"), []byte("{\n\t\"name\": \"acme/rules\",\n\t\"version\": \"1.0.0\",\n\t\"rules\": {},\n\t\"config\": {\n\t\t\"registries\": {\"@acme\": \"https://rules.acme.dev\"}\n\t}\n}\n"), 0644)
+	if path := ProjectConfigPath(); path != filepath.Join(repo, "rules.json") {
+		t.Errorf("Expected rules.json to be the project config, got %s", path)
+	}
+
+	cfg, err := Initialize()
+	if err != nil {
+		t.Fatalf("Initialize() failed: %v", err)
+	}
+	if url := cfg.RegistryURLForOwner("acme"); url != "https://rules.acme.dev" {
+		t.Errorf("Expected registry from rules.json config, got %s", url)
+	}
+
+	file, err := LoadFile(filepath.Join(repo, "rules.json"))
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if keys := file.Keys(); len(keys) != 1 || keys[0] != "registries" {
+		t.Errorf("Expected only the config block to be read, got %v", keys)
+	}
+	file.Set("default_format", "cursor")
+	if err := file.Save(); err == nil {
+		t.Error("Expected saving settings to rules.json to fail")
+	}
+}
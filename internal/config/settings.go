@@ -0,0 +1,422 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"rules-cli/internal/signing"
+)
+
+// SettingType is the type of a setting's value
+type SettingType int
+
+const (
+	// StringSetting holds a single value
+	StringSetting SettingType = iota
+	// ListSetting holds a list of values
+	ListSetting
+	// MapSetting holds named values, addressed as "<key>.<name>"
+	MapSetting
+)
+
+// Setting describes a config key that can be read and written with
+// 'rules config'
+type Setting struct {
+	Key         string
+	Type        SettingType
+	Default     interface{}
+	Description string
+	// Validate, if set, checks a single value (each item of a list or map)
+	Validate func(value string) error
+	// UserOnly settings are ignored in the project config, which anyone
+	// with access to a repository can change
+	UserOnly bool
+}
+
+// Settings are the known config keys
+var Settings = []Setting{
+	{Key: "registry_url", Type: StringSetting, Default: defaultAPIBase, Description: "Default registry", Validate: validateURL},
+	{Key: "registries", Type: MapSetting, Default: map[string]string{}, Description: "Registries for owner scopes, e.g. registries.@acme", Validate: validateURL},
+	{Key: "default_format", Type: StringSetting, Default: "default", Description: "Rule format used when --format isn't given"},
+	{Key: "targets", Type: ListSetting, Default: []string{}, Description: "Formats rendered by 'rules render' and re-rendered when rules change", Validate: validateTarget},
+	{Key: "block_position", Type: StringSetting, Default: string(DefaultBlockPosition), Description: "Where rendering adds its block to single files without one: top or bottom", Validate: validateBlockPosition},
+	{Key: "username", Type: StringSetting, Default: "", Description: "Your username"},
+	{Key: "email", Type: StringSetting, Default: "", Description: "Your email"},
+	{Key: "trusted_keys", Type: ListSetting, Default: []string{}, Description: "Public keys whose package signatures are accepted", Validate: validatePublicKey},
+	{Key: "trust_project_keys", Type: StringSetting, Default: "false", Description: "Also accept the trusted_keys of project configs: true or false", Validate: validateBool, UserOnly: true},
+	{Key: "signature_policy", Type: StringSetting, Default: string(signing.DefaultPolicy), Description: "What to do with unverified packages: off, warn or require", Validate: validatePolicy},
+	{Key: "profile", Type: StringSetting, Default: "", Description: "Credentials profile to use"},
+	{Key: "app_url", Type: StringSetting, Default: defaultAppURL, Description: "Continue Hub URL", Validate: validateURL, UserOnly: true},
+	{Key: "api_base", Type: StringSetting, Default: defaultAPIBase, Description: "Continue API URL used for authentication", Validate: validateURL, UserOnly: true},
+	{Key: "github_api_url", Type: StringSetting, Default: "https://api.github.com", Description: "GitHub API URL used for gh: packages", Validate: validateURL, UserOnly: true},
+	{Key: "workos_client_id", Type: StringSetting, Default: defaultClientID, Description: "WorkOS client ID used to log in", UserOnly: true},
+	{Key: "workos_api_url", Type: StringSetting, Default: "https://api.workos.com", Description: "WorkOS API URL used to log in", Validate: validateURL, UserOnly: true},
+}
+
+// LookupSetting finds the setting for a key. Entries of a map setting are
+// addressed as "<key>.<name>", and the name is returned along with it.
+func LookupSetting(key string) (Setting, string, error) {
+	base, name, hasName := strings.Cut(key, ".")
+	for _, setting := range Settings {
+		if setting.Key != base {
+			continue
+		}
+		if hasName && (setting.Type != MapSetting || name == "") {
+			return Setting{}, "", fmt.Errorf("unknown config key %q", key)
+		}
+		return setting, name, nil
+	}
+	return Setting{}, "", fmt.Errorf("unknown config key %q; run 'rules config list' to see all keys", key)
+}
+
+// EnvVar returns the environment variable that overrides the setting, or
+// "" for map settings, which can't be set from the environment
+func (s Setting) EnvVar() string {
+	if s.Type == MapSetting {
+		return ""
+	}
+	return "RULES_" + strings.ToUpper(s.Key)
+}
+
+// ParseValue checks and converts the command line values for a setting:
+// one value for strings and map entries, one or more for lists
+func (s Setting) ParseValue(name string, values []string) (interface{}, error) {
+	if s.Type == MapSetting && name == "" {
+		return nil, fmt.Errorf("%s is a map; set an entry with %s.<name>", s.Key, s.Key)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%s needs a value", s.Key)
+	}
+	if s.Type != ListSetting && len(values) > 1 {
+		return nil, fmt.Errorf("%s takes a single value, got %d", s.Key, len(values))
+	}
+
+	for _, value := range values {
+		if s.Validate == nil {
+			continue
+		}
+		if err := s.Validate(value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %w", s.Key, err)
+		}
+	}
+
+	if s.Type == ListSetting {
+		return values, nil
+	}
+	return values[0], nil
+}
+
+// Source is where an effective setting value comes from
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceGlobal  Source = "global"
+	SourceProject Source = "project"
+	SourceEnv     Source = "env"
+)
+
+// ResolvedSetting is the effective value of a setting and where it comes from
+type ResolvedSetting struct {
+	Key   string
+	Value interface{}
+	// Sources lists every layer the value comes from; trusted_keys combines
+	// the global and project files when trust_project_keys is set
+	Sources []Source
+}
+
+// Resolve returns the effective value of every setting, or of the settings
+// matching key if it isn't empty, along with their sources
+func Resolve(key string) ([]ResolvedSetting, error) {
+	global, err := LoadFile(GlobalConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	var project *File
+	if path := ProjectConfigPath(); path != "" && !sameFile(path, global.Path) {
+		if project, err = LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	var resolved []ResolvedSetting
+	for _, setting := range Settings {
+		if key != "" && setting.Key != key && !strings.HasPrefix(key, setting.Key+".") {
+			continue
+		}
+		resolved = append(resolved, resolveSetting(setting, key, global, project)...)
+	}
+	return resolved, nil
+}
+
+// resolveSetting resolves one setting across the config layers. Map
+// settings are resolved per entry.
+func resolveSetting(setting Setting, key string, global, project *File) []ResolvedSetting {
+	if setting.Type == MapSetting {
+		entries := map[string]ResolvedSetting{}
+		for _, layer := range []struct {
+			file   *File
+			source Source
+		}{{global, SourceGlobal}, {project, SourceProject}} {
+			if layer.file == nil {
+				continue
+			}
+			value, ok := layer.file.Get(setting.Key)
+			if !ok {
+				continue
+			}
+			m, _ := value.(map[string]interface{})
+			for name, v := range m {
+				entryKey := setting.Key + "." + name
+				entries[entryKey] = ResolvedSetting{Key: entryKey, Value: v, Sources: []Source{layer.source}}
+			}
+		}
+
+		var resolved []ResolvedSetting
+		for entryKey, entry := range entries {
+			if key == "" || key == setting.Key || key == entryKey {
+				resolved = append(resolved, entry)
+			}
+		}
+		sort.Slice(resolved, func(i, j int) bool { return resolved[i].Key < resolved[j].Key })
+		return resolved
+	}
+
+	if value, ok := os.LookupEnv(setting.EnvVar()); ok {
+		var v interface{} = value
+		if setting.Type == ListSetting {
+			v = strings.Fields(value)
+		}
+		return []ResolvedSetting{{Key: setting.Key, Value: v, Sources: []Source{SourceEnv}}}
+	}
+
+	result := ResolvedSetting{Key: setting.Key, Value: setting.Default, Sources: []Source{SourceDefault}}
+	globalValue, inGlobal := global.Get(setting.Key)
+	var projectValue interface{}
+	inProject := false
+	if project != nil {
+		projectValue, inProject = project.Get(setting.Key)
+	}
+
+	userValue := setting.Default
+	if inGlobal {
+		userValue = globalValue
+	}
+	if inProject && ignoredProjectValue(setting, projectValue, userValue, trustsProjectKeys(global)) != "" {
+		inProject = false
+	}
+
+	switch {
+	case setting.Key == "trusted_keys" && inGlobal && inProject:
+		// Trusted keys from both files are combined
+		result.Value = appendUnique(toStrings(globalValue), toStrings(projectValue)...)
+		result.Sources = []Source{SourceGlobal, SourceProject}
+	case inProject:
+		result.Value = projectValue
+		result.Sources = []Source{SourceProject}
+	case inGlobal:
+		result.Value = globalValue
+		result.Sources = []Source{SourceGlobal}
+	}
+
+	// registries.default overrides registry_url, as in Initialize
+	if setting.Key == "registry_url" {
+		if entry, ok := resolveDefaultRegistry(global, project); ok {
+			result.Value = entry.Value
+			result.Sources = entry.Sources
+		}
+	}
+	return []ResolvedSetting{result}
+}
+
+// resolveDefaultRegistry resolves registries.default, reporting whether it
+// is set and applies: RULES_REGISTRY_URL takes precedence over it
+func resolveDefaultRegistry(global, project *File) (ResolvedSetting, bool) {
+	if os.Getenv("RULES_REGISTRY_URL") != "" {
+		return ResolvedSetting{}, false
+	}
+
+	entryKey := "registries." + DefaultRegistryScope
+	setting, _, err := LookupSetting(entryKey)
+	if err != nil {
+		return ResolvedSetting{}, false
+	}
+	for _, entry := range resolveSetting(setting, entryKey, global, project) {
+		if entry.Key == entryKey && fmt.Sprint(entry.Value) != "" {
+			return entry, true
+		}
+	}
+	return ResolvedSetting{}, false
+}
+
+// sameFile reports whether two paths, either of which may be relative,
+// refer to the same file
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	return absA == absB
+}
+
+// IgnoredProjectValue returns why the value the project config file sets for
+// key is ignored, or "" if it applies, judged against the global config and
+// the environment as when the config is loaded
+func IgnoredProjectValue(key string, value interface{}) (string, error) {
+	setting, _, err := LookupSetting(key)
+	if err != nil {
+		return "", err
+	}
+	if sameFile(ProjectConfigPath(), GlobalConfigPath()) {
+		return "", nil
+	}
+	global, err := LoadFile(GlobalConfigPath())
+	if err != nil {
+		return "", err
+	}
+
+	userValue := setting.Default
+	if globalValue, ok := global.Get(setting.Key); ok {
+		userValue = globalValue
+	}
+	if envVar := setting.EnvVar(); envVar != "" {
+		if envValue, ok := os.LookupEnv(envVar); ok {
+			userValue = envValue
+		}
+	}
+	return ignoredProjectValue(setting, value, userValue, trustsProjectKeys(global)), nil
+}
+
+// trustsProjectKeys reports whether trust_project_keys is set in the
+// environment or, failing that, the global config
+func trustsProjectKeys(global *File) bool {
+	value, ok := os.LookupEnv("RULES_TRUST_PROJECT_KEYS")
+	if !ok {
+		globalValue, inGlobal := global.Get("trust_project_keys")
+		if !inGlobal {
+			return false
+		}
+		value = fmt.Sprint(globalValue)
+	}
+	trust, _ := strconv.ParseBool(value)
+	return trust
+}
+
+// ignoredProjectValue returns why the project config's value of a setting
+// is ignored, or "" if it applies. userValue is the value from the global
+// config, or the default. A project can't set user-only settings, can only
+// make the signature policy stricter and can only add trusted keys if the
+// user trusts project keys.
+func ignoredProjectValue(setting Setting, projectValue, userValue interface{}, trustProjectKeys bool) string {
+	if setting.UserOnly {
+		return "it can only be set in the global config or the environment"
+	}
+
+	if setting.Key == "trusted_keys" && !trustProjectKeys {
+		return "set trust_project_keys to true in the global config to accept keys from projects"
+	}
+
+	if setting.Key == "signature_policy" {
+		projectPolicy, err := signing.ParsePolicy(fmt.Sprint(projectValue))
+		if err != nil {
+			return err.Error()
+		}
+		userPolicy, err := signing.ParsePolicy(fmt.Sprint(userValue))
+		if err == nil && projectPolicy.Weaker(userPolicy) {
+			return fmt.Sprintf("it is weaker than the user policy %q", userPolicy)
+		}
+	}
+
+	return ""
+}
+
+// appendUnique appends the values not already in list
+func appendUnique(list []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, existing := range list {
+			if existing == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// toStrings converts a decoded YAML value to a list of strings
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, fmt.Sprint(item))
+		}
+		return result
+	case []string:
+		return v
+	case nil:
+		return nil
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
+
+// FormatValue formats a setting value for display: lists are comma
+// separated
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}, []string:
+		return strings.Join(toStrings(v), ", ")
+	case map[string]interface{}, map[string]string:
+		return fmt.Sprint(v)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func validateURL(value string) error {
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return fmt.Errorf("%q is not an http or https URL", value)
+	}
+	return nil
+}
+
+func validateTarget(value string) error {
+	if value == "" || value == "default" {
+		return fmt.Errorf("%q can't be rendered to, as it is where rules are kept", value)
+	}
+	return nil
+}
+
+func validateBlockPosition(value string) error {
+	_, err := ParseBlockPosition(value)
+	return err
+}
+
+func validateBool(value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("%q is not true or false", value)
+	}
+	return nil
+}
+
+func validatePolicy(value string) error {
+	_, err := signing.ParsePolicy(value)
+	return err
+}
+
+func validatePublicKey(value string) error {
+	_, err := signing.ParsePublicKey(value)
+	return err
+}
@@ -0,0 +1,130 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"rules-cli/internal/config"
+)
+
+// Markers of the block that rendering manages in a single file target.
+// Content outside the block is written by hand and kept.
+const (
+	BlockBegin = "<!-- rules:begin -->"
+	BlockEnd   = "<!-- rules:end -->"
+)
+
+// blockPosition is where new managed blocks are added
+var blockPosition = config.DefaultBlockPosition
+
+// SetBlockPosition sets where managed blocks are added to single files that
+// don't have one yet
+func SetBlockPosition(position config.BlockPosition) {
+	blockPosition = position
+}
+
+// mergeManagedBlock puts the rendered rules into the managed block of a
+// single file's existing content. An existing block is replaced in place;
+// otherwise a block is added at the configured position. If generated is
+// true, the existing content was rendered before blocks existed and is
+// replaced as a whole.
+func mergeManagedBlock(existing, rendered []byte, generated bool) ([]byte, error) {
+	var block bytes.Buffer
+	block.WriteString(BlockBegin + "\n")
+	block.Write(bytes.TrimRight(rendered, " \t\r\n"))
+	block.WriteString("\n" + BlockEnd)
+
+	begin := markerIndex(existing, BlockBegin, 0)
+	if begin >= 0 {
+		end := markerIndex(existing, BlockEnd, begin)
+		if end < 0 {
+			return nil, fmt.Errorf("found %s without %s", BlockBegin, BlockEnd)
+		}
+
+		var result bytes.Buffer
+		result.Write(existing[:begin])
+		result.Write(block.Bytes())
+		result.Write(existing[end+len(BlockEnd):])
+		return result.Bytes(), nil
+	}
+
+	content := bytes.TrimSpace(existing)
+	if len(content) == 0 || generated {
+		return append(block.Bytes(), '\n'), nil
+	}
+
+	var result bytes.Buffer
+	if blockPosition == config.BlockTop {
+		result.Write(block.Bytes())
+		result.WriteString("\n\n")
+		result.Write(content)
+	} else {
+		result.Write(content)
+		result.WriteString("\n\n")
+		result.Write(block.Bytes())
+	}
+	result.WriteString("\n")
+	return result.Bytes(), nil
+}
+
+// removeManagedBlock takes the managed block out of the content of a single
+// file, along with the blank lines that separated it from the rest. ok is
+// false if the content has no complete block.
+func removeManagedBlock(content []byte) (remaining []byte, ok bool) {
+	begin := markerIndex(content, BlockBegin, 0)
+	if begin < 0 {
+		return nil, false
+	}
+	end := markerIndex(content, BlockEnd, begin)
+	if end < 0 {
+		return nil, false
+	}
+
+	before := bytes.TrimRight(content[:begin], " \t\r\n")
+	after := bytes.TrimLeft(content[end+len(BlockEnd):], " \t\r\n")
+
+	var result bytes.Buffer
+	result.Write(before)
+	if len(before) > 0 && len(after) > 0 {
+		result.WriteString("\n\n")
+	}
+	result.Write(after)
+	if result.Len() > 0 && len(after) == 0 {
+		result.WriteString("\n")
+	}
+	return result.Bytes(), true
+}
+
+// managedBlock returns the managed block of a single file's content,
+// including its markers. ok is false if the content has no complete block.
+func managedBlock(content []byte) (block []byte, ok bool) {
+	begin := markerIndex(content, BlockBegin, 0)
+	if begin < 0 {
+		return nil, false
+	}
+	end := markerIndex(content, BlockEnd, begin)
+	if end < 0 {
+		return nil, false
+	}
+	return content[begin : end+len(BlockEnd)], true
+}
+
+// markerIndex returns the index of the first line at or after from that is
+// marker, or -1
+func markerIndex(content []byte, marker string, from int) int {
+	for offset := from; offset < len(content); {
+		line, _, _ := bytes.Cut(content[offset:], []byte("\n"))
+		if string(bytes.TrimSpace(line)) == marker {
+			return offset + bytes.Index(line, []byte(marker))
+		}
+		offset += len(line) + 1
+	}
+	return -1
+}
+
+// isBlockMarker reports whether a line is one of the managed block markers
+func isBlockMarker(line string) bool {
+	line = strings.TrimSpace(line)
+	return line == BlockBegin || line == BlockEnd
+}
@@ -0,0 +1,106 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"rules-cli/internal/config"
+)
+
+func TestMergeManagedBlock(t *testing.T) {
+	block := BlockBegin + "\n# Rules\n\n## Style\n\nUse gofmt.\n" + BlockEnd
+
+	tests := []struct {
+		name      string
+		existing  string
+		generated bool
+		position  config.BlockPosition
+		expected  string
+	}{
+		{
+			name:     "new file",
+			expected: block + "\n",
+		},
+		{
+			name:     "replaces the existing block only",
+			existing: "# Project\n\nNotes.\n\n" + BlockBegin + "\nold rules\n" + BlockEnd + "\n\n## More notes\n",
+			expected: "# Project\n\nNotes.\n\n" + block + "\n\n## More notes\n",
+		},
+		{
+			name:     "adds the block at the bottom",
+			existing: "# Project\n\nNotes.\n",
+			position: config.BlockBottom,
+			expected: "# Project\n\nNotes.\n\n" + block + "\n",
+		},
+		{
+			name:     "adds the block at the top",
+			existing: "# Project\n\nNotes.\n",
+			position: config.BlockTop,
+			expected: block + "\n\n# Project\n\nNotes.\n",
+		},
+		{
+			name:      "replaces a file rendered without a block",
+			existing:  "# Rules\n\n## Old\n\nOld rule.\n",
+			generated: true,
+			expected:  block + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			position := tt.position
+			if position == "" {
+				position = config.DefaultBlockPosition
+			}
+			SetBlockPosition(position)
+			defer SetBlockPosition(config.DefaultBlockPosition)
+
+			merged, err := mergeManagedBlock([]byte(tt.existing), []byte("# Rules\n\n## Style\n\nUse gofmt.\n\n"), tt.generated)
+			if err != nil {
+				t.Fatalf("mergeManagedBlock failed: %v", err)
+			}
+			if string(merged) != tt.expected {
+				t.Errorf("Expected\n%q\ngot\n%q", tt.expected, merged)
+			}
+		})
+	}
+
+	if _, err := mergeManagedBlock([]byte(BlockBegin+"\nrules\n"), []byte("# Rules\n"), false); err == nil {
+		t.Error("Expected a block without an end marker to fail")
+	}
+}
+
+func TestRenderRulesToFormat_KeepsContentOutsideBlock(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(".rules", 0755)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n\nUse gofmt.\n"), 0644)
+	os.WriteFile("CLAUDE.md", []byte("# Project notes\n\nRun make before committing.\n"), 0644)
+
+	for i := 0; i < 2; i++ {
+		if _, err := RenderRulesToFormat(".rules", "claude", false); err != nil {
+			t.Fatalf("RenderRulesToFormat failed: %v", err)
+		}
+	}
+
+	content, _ := os.ReadFile("CLAUDE.md")
+	expected := "# Project notes\n\nRun make before committing.\n\n" +
+		BlockBegin + "\n# Rules\n\n## Style\n\n# Style\n\nUse gofmt.\n" + BlockEnd + "\n"
+	if string(content) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, content)
+	}
+
+	// Edits outside the block are kept, edits inside it are overwritten
+	edited := strings.Replace(string(content), "Run make", "Run make test", 1)
+	edited = strings.Replace(edited, "Use gofmt.", "Anything goes.", 1)
+	os.WriteFile("CLAUDE.md", []byte(edited), 0644)
+	if _, err := RenderRulesToFormat(".rules", "claude", false); err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+	content, _ = os.ReadFile("CLAUDE.md")
+	if !strings.Contains(string(content), "Run make test") || !strings.Contains(string(content), "Use gofmt.") {
+		t.Errorf("Unexpected content after re-rendering:\n%s", content)
+	}
+}
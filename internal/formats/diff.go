@@ -0,0 +1,144 @@
+package formats
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp is a line of an edit script: ' ' kept, '-' removed or '+' added
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// UnifiedDiff returns the changes from the content on disk to the rendered
+// content of a file as a unified diff, like 'diff -u'
+func UnifiedDiff(change FileChange) string {
+	oldName, newName := "a/"+change.Path, "b/"+change.Path
+	if change.Kind == FileAdded {
+		oldName = "/dev/null"
+	}
+	if change.Kind == FileRemoved {
+		newName = "/dev/null"
+	}
+
+	ops := diffLines(splitLines(string(change.Old)), splitLines(string(change.New)))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// Group changes that are close together into hunks
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+
+		first := max(start-diffContext, 0)
+		last := start
+		for i := start; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				last = i
+			} else if i-last > 2*diffContext {
+				break
+			}
+		}
+		end := min(last+diffContext+1, len(ops))
+
+		writeHunk(&out, ops, first, end)
+		start = end
+	}
+
+	return out.String()
+}
+
+// writeHunk writes ops[first:end] as a hunk with its line numbers
+func writeHunk(out *strings.Builder, ops []diffOp, first, end int) {
+	oldLine, newLine := 1, 1
+	for _, op := range ops[:first] {
+		if op.kind != '+' {
+			oldLine++
+		}
+		if op.kind != '-' {
+			newLine++
+		}
+	}
+
+	oldCount, newCount := 0, 0
+	for _, op := range ops[first:end] {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+	// An empty range starts at the line before it
+	if oldCount == 0 {
+		oldLine--
+	}
+	if newCount == 0 {
+		newLine--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", oldLine, oldCount, newLine, newCount)
+	for _, op := range ops[first:end] {
+		out.WriteByte(op.kind)
+		out.WriteString(op.line)
+		out.WriteByte('\n')
+	}
+}
+
+// diffLines computes an edit script from a to b using the longest common
+// subsequence of their lines. Rendered files are small, so the quadratic
+// table is fine.
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j]})
+	}
+	return ops
+}
+
+// splitLines splits content into lines without their line endings
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
@@ -0,0 +1,45 @@
+package formats
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   FileChange
+		expected string
+	}{
+		{
+			name:   "changed line",
+			change: FileChange{Path: "CLAUDE.md", Kind: FileChanged, Old: []byte("a\nb\nc\n"), New: []byte("a\nB\nc\n")},
+			expected: "--- a/CLAUDE.md\n+++ b/CLAUDE.md\n" +
+				"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name:   "added file",
+			change: FileChange{Path: "x.md", Kind: FileAdded, New: []byte("a\nb\n")},
+			expected: "--- /dev/null\n+++ b/x.md\n" +
+				"@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name:   "removed file",
+			change: FileChange{Path: "x.md", Kind: FileRemoved, Old: []byte("a\n")},
+			expected: "--- a/x.md\n+++ /dev/null\n" +
+				"@@ -1,1 +0,0 @@\n-a\n",
+		},
+		{
+			name:   "separate hunks",
+			change: FileChange{Path: "x.md", Kind: FileChanged, Old: []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"), New: []byte("one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n")},
+			expected: "--- a/x.md\n+++ b/x.md\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n" +
+				"@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := UnifiedDiff(tt.change); diff != tt.expected {
+				t.Errorf("Expected\n%s\ngot\n%s", tt.expected, diff)
+			}
+		})
+	}
+}
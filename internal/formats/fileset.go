@@ -0,0 +1,127 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// FileSet is a set of rendered files, keyed by their slash separated path
+// relative to the project root
+type FileSet map[string][]byte
+
+// Paths returns the paths of the files in sorted order
+func (f FileSet) Paths() []string {
+	paths := make([]string, 0, len(f))
+	for path := range f {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Write writes the files to disk, creating their directories, and returns
+// their paths
+func (f FileSet) Write() ([]string, error) {
+	paths := f.Paths()
+	for _, path := range paths {
+		localPath := filepath.FromSlash(path)
+		if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create target directory %s: %w", filepath.Dir(localPath), err)
+		}
+		if err := os.WriteFile(localPath, f[path], 0644); err != nil {
+			return nil, fmt.Errorf("failed to write to target file %s: %w", localPath, err)
+		}
+	}
+	return paths, nil
+}
+
+// ChangeKind is how rendering would change a file
+type ChangeKind string
+
+const (
+	FileAdded   ChangeKind = "added"
+	FileChanged ChangeKind = "changed"
+	FileRemoved ChangeKind = "removed"
+)
+
+// FileChange is a difference between the rendered files and those on disk
+type FileChange struct {
+	Path string
+	Kind ChangeKind
+	// Old is the content on disk and New the rendered content; either is
+	// nil when the file is added or removed
+	Old []byte
+	New []byte
+}
+
+// DiffRender renders rules to the target format in memory and compares the
+// result with the files on disk, without writing anything. Files listed in
+// the target's manifest that would no longer be rendered are reported as
+// removed, or as changed for single files that keep content outside their
+// managed block, unless they were changed since they were rendered.
+func DiffRender(sourceDir string, targetFormatName string) ([]FileChange, error) {
+	targetFormat := GetFormat(targetFormatName)
+
+	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("source directory %s does not exist", sourceDir)
+	}
+
+	files, err := BuildRuleFiles(sourceDir, targetFormat)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := LoadManifest(sourceDir, targetFormat.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []FileChange
+	for _, path := range files.Paths() {
+		current, err := os.ReadFile(filepath.FromSlash(path))
+		switch {
+		case os.IsNotExist(err):
+			changes = append(changes, FileChange{Path: path, Kind: FileAdded, New: files[path]})
+		case err != nil:
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		case !bytes.Equal(current, files[path]):
+			changes = append(changes, FileChange{Path: path, Kind: FileChanged, Old: current, New: files[path]})
+		}
+	}
+
+	for _, path := range previous.Files {
+		if _, ok := files[path]; ok || !isFormatOutput(targetFormat, path) {
+			continue
+		}
+		remaining, stale, err := staleFileContent(targetFormat, path)
+		if err != nil {
+			return nil, err
+		}
+		if !stale {
+			continue
+		}
+		// Files changed since they were rendered are kept
+		if matches, err := previous.matchesRender(targetFormat, path); err != nil {
+			return nil, err
+		} else if !matches {
+			continue
+		}
+		current, err := os.ReadFile(filepath.FromSlash(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		// A single file with content outside its block only loses the block
+		if remaining != nil {
+			changes = append(changes, FileChange{Path: path, Kind: FileChanged, Old: current, New: remaining})
+			continue
+		}
+		changes = append(changes, FileChange{Path: path, Kind: FileRemoved, Old: current})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
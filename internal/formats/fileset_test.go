@@ -0,0 +1,65 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDiffRender(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(".rules", 0755)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "testing.md"), []byte("# Testing\n"), 0644)
+
+	changes, err := DiffRender(".rules", "cursor")
+	if err != nil {
+		t.Fatalf("DiffRender failed: %v", err)
+	}
+	if kinds := changeKinds(changes); !reflect.DeepEqual(kinds, map[string]ChangeKind{
+		".cursor/rules/style.mdc":   FileAdded,
+		".cursor/rules/testing.mdc": FileAdded,
+	}) {
+		t.Errorf("Unexpected changes %v", kinds)
+	}
+	if _, err := os.Stat(".cursor"); !os.IsNotExist(err) {
+		t.Error("Expected DiffRender not to write anything")
+	}
+
+	if _, err := RenderRulesToFormat(".rules", "cursor", false); err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+	changes, err = DiffRender(".rules", "cursor")
+	if err != nil {
+		t.Fatalf("DiffRender failed: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("Expected no changes after rendering, got %v", changeKinds(changes))
+	}
+
+	// Hand-made files in the target directory aren't reported
+	os.WriteFile(filepath.Join(".cursor", "rules", "local.mdc"), []byte("# Local\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n\nUse gofmt.\n"), 0644)
+	os.Remove(filepath.Join(".rules", "testing.md"))
+
+	changes, err = DiffRender(".rules", "cursor")
+	if err != nil {
+		t.Fatalf("DiffRender failed: %v", err)
+	}
+	if kinds := changeKinds(changes); !reflect.DeepEqual(kinds, map[string]ChangeKind{
+		".cursor/rules/style.mdc":   FileChanged,
+		".cursor/rules/testing.mdc": FileRemoved,
+	}) {
+		t.Errorf("Unexpected changes %v", kinds)
+	}
+}
+
+func changeKinds(changes []FileChange) map[string]ChangeKind {
+	kinds := map[string]ChangeKind{}
+	for _, change := range changes {
+		kinds[change.Path] = change.Kind
+	}
+	return kinds
+}
@@ -7,115 +7,62 @@ import (
 	"strings"
 )
 
-// Format represents a rules format
+// Format represents a rules format. Formats are defined in YAML, see
+// definitions/ for the built-in ones.
 type Format struct {
-	Name            string
-	DirectoryPrefix string
-	FileExtension   string
-	IsSingleFile    bool
-	SingleFilePath  string
-	Description     string
+	Name            string `yaml:"name"`
+	DirectoryPrefix string `yaml:"directory,omitempty"`
+	FileExtension   string `yaml:"extension,omitempty"`
+	IsSingleFile    bool   `yaml:"-"`
+	SingleFilePath  string `yaml:"file,omitempty"`
+	// Nested single file formats also render rules whose globs are rooted
+	// in a directory to a file of the same name in that directory, e.g.
+	// services/api/AGENTS.md for "services/api/**"
+	Nested      bool   `yaml:"nested,omitempty"`
+	Description string `yaml:"description,omitempty"`
+	// Frontmatter describes how rule frontmatter is translated to the format
+	Frontmatter FrontmatterMapping `yaml:"frontmatter,omitempty"`
+	// Source is where the definition comes from: BuiltinSource or the path
+	// of a user-defined format
+	Source string `yaml:"-"`
+}
+
+// FrontmatterMapping describes how the frontmatter of a rule in the default
+// format is translated to another format. The steps are applied in the order
+// of the fields.
+type FrontmatterMapping struct {
+	// Rename maps default format fields to the names the format uses
+	Rename map[string]string `yaml:"rename,omitempty"`
+	// Values translates the values of a (renamed) field, e.g. true to
+	// always_on. Values without a translation are replaced by the field's
+	// default, if it has one.
+	Values map[string]map[string]interface{} `yaml:"values,omitempty"`
+	// Defaults are the values of fields the rule doesn't set
+	Defaults map[string]interface{} `yaml:"defaults,omitempty"`
+	// Fields are the fields kept in the output; all fields are kept if empty
+	Fields []string `yaml:"fields,omitempty"`
+	// Fallback is the frontmatter used for rules that set none of Fields
+	Fallback map[string]interface{} `yaml:"fallback,omitempty"`
 }
 
 // GetFormat returns a Format for the given format name
 func GetFormat(formatName string) Format {
 	// Default to ".rules" if no format is specified or format is "default"
-	if formatName == "" || formatName == "default" {
-		return Format{
-			Name:            "default",
-			DirectoryPrefix: ".rules",
-			FileExtension:   ".md",
-			IsSingleFile:    false,
-			Description:     "Default rules format",
-		}
+	if formatName == "" {
+		formatName = "default"
 	}
 
-	// Define formats based on render-formats.md
-	switch formatName {
-	case "continue":
-		return Format{
-			Name:            "continue",
-			DirectoryPrefix: ".continue/rules",
-			FileExtension:   ".md",
-			IsSingleFile:    false,
-			Description:     "Continue Dev rules",
-		}
-	case "cursor":
-		return Format{
-			Name:            "cursor",
-			DirectoryPrefix: ".cursor/rules",
-			FileExtension:   ".mdc",
-			IsSingleFile:    false,
-			Description:     "Cursor rules",
-		}
-	case "windsurf":
-		return Format{
-			Name:            "windsurf",
-			DirectoryPrefix: ".windsurf/rules",
-			FileExtension:   ".md",
-			IsSingleFile:    false,
-			Description:     "Windsurf rules",
-		}
-	case "claude":
-		return Format{
-			Name:            "claude",
-			DirectoryPrefix: "",
-			FileExtension:   ".md",
-			IsSingleFile:    true,
-			SingleFilePath:  "CLAUDE.md",
-			Description:     "Claude Code single file",
-		}
-	case "copilot":
-		return Format{
-			Name:            "copilot",
-			DirectoryPrefix: ".github/instructions",
-			FileExtension:   ".instructions.md",
-			IsSingleFile:    false,
-			Description:     "GitHub Copilot instructions",
-		}
-	case "codex":
-		return Format{
-			Name:            "codex",
-			DirectoryPrefix: "",
-			FileExtension:   ".md",
-			IsSingleFile:    true,
-			SingleFilePath:  "AGENT.md",
-			Description:     "Codex single file",
-		}
-	case "cline":
-		return Format{
-			Name:            "cline",
-			DirectoryPrefix: ".clinerules",
-			FileExtension:   ".md",
-			IsSingleFile:    false,
-			Description:     "Cline rules",
-		}
-	case "cody":
-		return Format{
-			Name:            "cody",
-			DirectoryPrefix: ".sourcegraph",
-			FileExtension:   ".rule.md",
-			IsSingleFile:    false,
-			Description:     "Sourcegraph Cody rules",
-		}
-	case "amp":
-		return Format{
-			Name:            "amp",
-			DirectoryPrefix: "",
-			FileExtension:   ".md",
-			IsSingleFile:    true,
-			SingleFilePath:  "AGENT.md",
-			Description:     "Amp single file",
-		}
-	default:
-		// For any other format, use .<format>/rules
-		return Format{
-			Name:            formatName,
-			DirectoryPrefix: fmt.Sprintf(".%s/rules", formatName),
-			FileExtension:   ".md",
-			IsSingleFile:    false,
-			Description:     fmt.Sprintf("%s rules", formatName),
-		}
+	if format, ok := LookupFormat(formatName); ok {
+		return format
+	}
+
+	// For any other format, use .<format>/rules
+	return Format{
+		Name:            formatName,
+		DirectoryPrefix: fmt.Sprintf(".%s/rules", formatName),
+		FileExtension:   ".md",
+		IsSingleFile:    false,
+		Description:     fmt.Sprintf("%s rules", formatName),
 	}
 }
 
@@ -197,7 +144,7 @@ func GetFormatSuggestionMessage() (string, error) {
 	}
 
 	if len(formatFolders) > 0 {
-		return fmt.Sprintf("Found existing rules folder(s): %s\nConsider running 'rules render %s' to initialize rules.json from existing rules",
+		return fmt.Sprintf("Found existing rules folder(s): %s\nConsider running 'rules import %s' to import existing rules into .rules",
 			strings.Join(formatFolders, ", "), formatFolders[0]), nil
 	}
 
@@ -207,21 +154,6 @@ func GetFormatSuggestionMessage() (string, error) {
 // RenderRules renders rules from the source directory to the target format
 func RenderRules(sourceDir string, targetFormat Format) error {
 	// Use the transformer to process the rule files
-	return ProcessRuleFiles(sourceDir, targetFormat)
-}
-
-// GetAllFormats returns a list of all supported formats
-func GetAllFormats() []Format {
-	formats := []Format{
-		GetFormat("continue"),
-		GetFormat("cursor"),
-		GetFormat("windsurf"),
-		GetFormat("claude"),
-		GetFormat("copilot"),
-		GetFormat("codex"),
-		GetFormat("cline"),
-		GetFormat("cody"),
-		GetFormat("amp"),
-	}
-	return formats
+	_, err := ProcessRuleFiles(sourceDir, targetFormat)
+	return err
 }
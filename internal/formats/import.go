@@ -0,0 +1,205 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportedRule is a rule read from a format's directory and converted to the
+// default format
+type ImportedRule struct {
+	// Source is the path of the rule in the format's directory
+	Source string
+	// Name is the path of the rule relative to the rules directory
+	Name    string
+	Content []byte
+	// Losses describe anything that couldn't be converted
+	Losses []string
+}
+
+// ImportRules reads the rules in a directory based format and converts them
+// to the default format. Nothing is written.
+func ImportRules(format Format) ([]ImportedRule, error) {
+	if format.IsSingleFile {
+		return nil, fmt.Errorf("%s renders to a single file (%s); use 'rules import --split %s' to split it into rules", format.Name, format.SingleFilePath, format.Name)
+	}
+	if format.Name == "default" {
+		return nil, fmt.Errorf("cannot import from default format as it is the destination")
+	}
+
+	sourceDir := format.DirectoryPrefix
+	info, err := os.Stat(sourceDir)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("source directory %s does not exist", sourceDir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source directory %s: %w", sourceDir, err)
+	}
+	// Older versions of some tools kept their rules in a single file, e.g.
+	// a .clinerules file instead of a directory
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is a single file, not a directory of rules; use 'rules import --split %s' to split it into rules", sourceDir, sourceDir)
+	}
+
+	var rules []ImportedRule
+	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Only process rule files of the format
+		if info.IsDir() || !strings.HasSuffix(info.Name(), format.FileExtension) {
+			return nil
+		}
+
+		// Skip README.md files
+		if strings.ToLower(info.Name()) == "readme.md" {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read source file %s: %w", path, err)
+		}
+
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+
+		converted, losses, err := ReverseTransformRuleContent(content, format)
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %w", path, err)
+		}
+
+		rules = append(rules, ImportedRule{
+			Source:  path,
+			Name:    strings.TrimSuffix(relPath, format.FileExtension) + ".md",
+			Content: converted,
+			Losses:  losses,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// ReverseTransformRuleContent converts a rule from the given format back to
+// the default format, returning descriptions of anything that was lost
+func ReverseTransformRuleContent(content []byte, format Format) ([]byte, []string, error) {
+	metadata, bodyContent, err := ParseFrontmatter(content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	metadata, losses := ReverseTransformMetadata(metadata, format)
+	trimmedBodyContent := bytes.TrimSpace(bodyContent)
+
+	if len(metadata) == 0 {
+		return append(trimmedBodyContent, '\n'), losses, nil
+	}
+
+	metadataBytes, err := yaml.Marshal(metadata)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to serialize metadata: %w", err)
+	}
+
+	var result bytes.Buffer
+	result.WriteString("---\n")
+	result.Write(metadataBytes)
+	result.WriteString("---\n\n")
+	result.Write(trimmedBodyContent)
+	result.WriteString("\n")
+
+	return result.Bytes(), losses, nil
+}
+
+// ReverseTransformMetadata undoes the frontmatter mapping of a format:
+// translated values and renamed fields are mapped back, and values the
+// format adds by default are removed. Fields the default format doesn't have
+// are dropped and reported.
+func ReverseTransformMetadata(metadata RuleMetadata, format Format) (RuleMetadata, []string) {
+	mapping := format.Frontmatter
+	transformed := RuleMetadata{}
+	for k, v := range metadata {
+		transformed[k] = v
+	}
+
+	var losses []string
+
+	// Values the format fills in by default weren't set in the original
+	for field, value := range mapping.Defaults {
+		if _, translated := mapping.Values[field]; translated {
+			continue
+		}
+		if current, ok := transformed[field]; ok && fmt.Sprint(current) == fmt.Sprint(value) {
+			delete(transformed, field)
+		}
+	}
+
+	// Translate values back, e.g. trigger: always_on to alwaysApply: true
+	for field, translations := range mapping.Values {
+		value, ok := transformed[field]
+		if !ok {
+			continue
+		}
+		original, ok := reverseTranslation(translations, value)
+		if !ok {
+			losses = append(losses, fmt.Sprintf("%s: %v has no equivalent and was dropped", field, value))
+			delete(transformed, field)
+			continue
+		}
+		transformed[field] = original
+	}
+
+	// Rename fields back, e.g. applyTo to globs
+	for from, to := range mapping.Rename {
+		if value, ok := transformed[to]; ok {
+			delete(transformed, to)
+			transformed[from] = value
+		}
+	}
+
+	// Drop what the default format doesn't support, and empty values such as
+	// those of Cursor's fallback frontmatter
+	supported := GetFormat("default").Frontmatter.Fields
+	for k, v := range transformed {
+		if !slices.Contains(supported, k) {
+			losses = append(losses, fmt.Sprintf("%s is not supported and was dropped", k))
+			delete(transformed, k)
+			continue
+		}
+		if v == nil || v == "" {
+			delete(transformed, k)
+		}
+	}
+
+	sort.Strings(losses)
+	return transformed, losses
+}
+
+// reverseTranslation finds the original value that a format translates to
+// value. The original is parsed as YAML, so "true" becomes a boolean.
+func reverseTranslation(translations map[string]interface{}, value interface{}) (interface{}, bool) {
+	for original, translated := range translations {
+		if fmt.Sprint(translated) != fmt.Sprint(value) {
+			continue
+		}
+		var parsed interface{}
+		if err := yaml.Unmarshal([]byte(original), &parsed); err != nil || parsed == nil {
+			return original, true
+		}
+		return parsed, true
+	}
+	return nil, false
+}
@@ -0,0 +1,135 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReverseTransformMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		metadata RuleMetadata
+		expected RuleMetadata
+		losses   int
+	}{
+		{
+			name:     "windsurf always on trigger",
+			format:   "windsurf",
+			metadata: RuleMetadata{"trigger": "always_on", "description": "Style"},
+			expected: RuleMetadata{"alwaysApply": true, "description": "Style"},
+		},
+		{
+			name:     "windsurf manual trigger",
+			format:   "windsurf",
+			metadata: RuleMetadata{"trigger": "manual", "globs": "*.go"},
+			expected: RuleMetadata{"alwaysApply": false, "globs": "*.go"},
+		},
+		{
+			name:     "windsurf trigger without equivalent",
+			format:   "windsurf",
+			metadata: RuleMetadata{"trigger": "model_decision", "description": "Style"},
+			expected: RuleMetadata{"description": "Style"},
+			losses:   1,
+		},
+		{
+			name:     "copilot applyTo",
+			format:   "copilot",
+			metadata: RuleMetadata{"applyTo": "**/*.ts"},
+			expected: RuleMetadata{"globs": "**/*.ts"},
+		},
+		{
+			name:     "copilot default applyTo",
+			format:   "copilot",
+			metadata: RuleMetadata{"applyTo": "**", "description": "Everything"},
+			expected: RuleMetadata{"description": "Everything"},
+		},
+		{
+			name:     "cursor fallback",
+			format:   "cursor",
+			metadata: RuleMetadata{"description": nil, "globs": nil, "alwaysApply": true},
+			expected: RuleMetadata{"alwaysApply": true},
+		},
+		{
+			name:     "unsupported fields",
+			format:   "continue",
+			metadata: RuleMetadata{"name": "x", "tags": []interface{}{"go"}, "alwaysApply": false},
+			expected: RuleMetadata{"alwaysApply": false},
+			losses:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, losses := ReverseTransformMetadata(tt.metadata, GetFormat(tt.format))
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+			if len(losses) != tt.losses {
+				t.Errorf("Expected %d losses, got %v", tt.losses, losses)
+			}
+		})
+	}
+}
+
+func TestImportRules(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(filepath.Join(".cursor", "rules", "backend"), 0755)
+	os.WriteFile(filepath.Join(".cursor", "rules", "style.mdc"), []byte("---\ndescription:\nglobs:\nalwaysApply: true\n---\n\n# Style\n"), 0644)
+	os.WriteFile(filepath.Join(".cursor", "rules", "backend", "api.mdc"), []byte("---\ndescription: API rules\nglobs: \"api/**\"\nalwaysApply: false\n---\n\n# API\n"), 0644)
+	os.WriteFile(filepath.Join(".cursor", "rules", "notes.txt"), []byte("not a rule\n"), 0644)
+
+	rules, err := ImportRules(GetFormat("cursor"))
+	if err != nil {
+		t.Fatalf("ImportRules failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+
+	names := map[string]string{}
+	for _, rule := range rules {
+		names[rule.Name] = string(rule.Content)
+	}
+	api, ok := names[filepath.Join("backend", "api.md")]
+	if !ok {
+		t.Fatalf("Expected backend/api.md, got %v", names)
+	}
+	for _, expected := range []string{"alwaysApply: false", "description: API rules", "globs: api/**", "# API"} {
+		if !strings.Contains(api, expected) {
+			t.Errorf("Expected %q in imported rule:\n%s", expected, api)
+		}
+	}
+	if style := names["style.md"]; style != "---\nalwaysApply: true\n---\n\n# Style\n" {
+		t.Errorf("Unexpected imported style rule:\n%s", style)
+	}
+
+	// Rendering the imported rule gives back the original
+	rendered, err := TransformRuleContent([]byte(api), GetFormat("cursor"))
+	if err != nil {
+		t.Fatalf("TransformRuleContent failed: %v", err)
+	}
+	original, _ := os.ReadFile(filepath.Join(".cursor", "rules", "backend", "api.mdc"))
+	renderedMetadata, renderedBody, _ := ParseFrontmatter(rendered)
+	originalMetadata, originalBody, _ := ParseFrontmatter(original)
+	if !reflect.DeepEqual(renderedMetadata, originalMetadata) || strings.TrimSpace(string(renderedBody)) != strings.TrimSpace(string(originalBody)) {
+		t.Errorf("Expected round trip to give\n%s\ngot\n%s", original, rendered)
+	}
+
+	if _, err := ImportRules(GetFormat("claude")); err == nil {
+		t.Error("Expected importing a single file format to fail")
+	}
+	if _, err := ImportRules(GetFormat("windsurf")); err == nil {
+		t.Error("Expected importing a missing directory to fail")
+	}
+
+	// A legacy single rules file is pointed at --split
+	os.WriteFile(".clinerules", []byte("# Rules\n"), 0644)
+	if _, err := ImportRules(GetFormat("cline")); err == nil || !strings.Contains(err.Error(), "--split .clinerules") {
+		t.Errorf("Expected importing a single .clinerules file to suggest --split, got %v", err)
+	}
+}
@@ -0,0 +1,228 @@
+package formats
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestDirName is the directory in the rules directory that holds a
+// manifest of the files rendered to each target
+const ManifestDirName = ".manifests"
+
+// Manifest lists the files that were rendered to a target, so that files
+// whose rule no longer exists can be removed by the next render. Files that
+// aren't listed, such as those created by hand, are never removed, and
+// neither are listed files edited since they were rendered.
+type Manifest struct {
+	Format string   `json:"format"`
+	Files  []string `json:"files"`
+	// Hashes are the SHA-256 hashes of what was rendered to each file: the
+	// whole file, or the managed block of a single file
+	Hashes map[string]string `json:"hashes,omitempty"`
+}
+
+// newManifest records the files rendered to a target along with their hashes
+func newManifest(format Format, files FileSet) Manifest {
+	manifest := Manifest{Format: format.Name, Files: files.Paths(), Hashes: map[string]string{}}
+	for path, content := range files {
+		manifest.Hashes[path] = renderedHash(format, content)
+	}
+	return manifest
+}
+
+// renderedHash returns the hash of the rendered part of a file's content
+func renderedHash(format Format, content []byte) string {
+	if format.IsSingleFile {
+		if block, ok := managedBlock(content); ok {
+			content = block
+		}
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// matchesRender reports whether the file at path still holds what the
+// manifest recorded rendering to it. Files without a recorded hash never
+// match.
+func (m Manifest) matchesRender(format Format, path string) (bool, error) {
+	hash, ok := m.Hashes[path]
+	if !ok {
+		return false, nil
+	}
+	content, err := os.ReadFile(filepath.FromSlash(path))
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return renderedHash(format, content) == hash, nil
+}
+
+// manifestPath returns the path of the manifest of a target
+func manifestPath(sourceDir, formatName string) string {
+	return filepath.Join(sourceDir, ManifestDirName, formatName+".json")
+}
+
+// LoadManifest reads the manifest of a target. A target that was never
+// rendered has an empty manifest.
+func LoadManifest(sourceDir, formatName string) (Manifest, error) {
+	path := manifestPath(sourceDir, formatName)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return Manifest{Format: formatName}, nil
+	}
+	if err != nil {
+		return Manifest{}, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Manifest{}, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return manifest, nil
+}
+
+// Save writes the manifest of a target to the rules directory
+func (m Manifest) Save(sourceDir string) error {
+	path := manifestPath(sourceDir, m.Format)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+
+	sort.Strings(m.Files)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize manifest: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+// isManifestPath reports whether path is in the manifest directory of the
+// rules directory sourceDir
+func isManifestPath(sourceDir, path string) bool {
+	relPath, err := filepath.Rel(sourceDir, path)
+	if err != nil {
+		return false
+	}
+	return strings.SplitN(filepath.ToSlash(relPath), "/", 2)[0] == ManifestDirName
+}
+
+// removeStaleFiles removes the files of the previous manifest that weren't
+// rendered again, and returns their paths. Only files the format could have
+// rendered are considered, so a manifest edited by hand can't remove
+// anything else. Single files only lose their managed block, and are
+// removed if nothing else is left. Files that no longer match what was
+// rendered to them are kept, and returned separately.
+func removeStaleFiles(format Format, previous Manifest, rendered []string) (removed, kept []string, err error) {
+	current := map[string]bool{}
+	for _, path := range rendered {
+		current[filepath.ToSlash(path)] = true
+	}
+
+	for _, path := range previous.Files {
+		if current[path] || !isFormatOutput(format, path) {
+			continue
+		}
+
+		localPath := filepath.FromSlash(path)
+		remaining, stale, err := staleFileContent(format, path)
+		if err != nil {
+			return removed, kept, err
+		}
+		if !stale {
+			continue
+		}
+
+		matches, err := previous.matchesRender(format, path)
+		if err != nil {
+			return removed, kept, err
+		}
+		if !matches {
+			kept = append(kept, path)
+			continue
+		}
+
+		if remaining != nil {
+			if err := os.WriteFile(localPath, remaining, 0644); err != nil {
+				return removed, kept, fmt.Errorf("failed to update stale file %s: %w", localPath, err)
+			}
+		} else if err := os.Remove(localPath); err != nil {
+			return removed, kept, fmt.Errorf("failed to remove stale file %s: %w", localPath, err)
+		}
+		removed = append(removed, path)
+
+		if !format.IsSingleFile {
+			removeEmptyParents(filepath.Dir(localPath), filepath.FromSlash(format.DirectoryPrefix))
+		}
+	}
+
+	return removed, kept, nil
+}
+
+// staleFileContent returns what is left of a stale rendered file once the
+// rendered content is taken out: nil if the file is removed as a whole, or
+// the content outside the managed block of a single file that has any.
+// stale is false if the file no longer exists, or is a single file without
+// a managed block, which is left alone.
+func staleFileContent(format Format, path string) (remaining []byte, stale bool, err error) {
+	localPath := filepath.FromSlash(path)
+	if !format.IsSingleFile {
+		if _, err := os.Stat(localPath); os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, true, nil
+	}
+
+	content, err := os.ReadFile(localPath)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read %s: %w", localPath, err)
+	}
+
+	remaining, ok := removeManagedBlock(content)
+	if !ok {
+		return nil, false, nil
+	}
+	if len(bytes.TrimSpace(remaining)) == 0 {
+		return nil, true, nil
+	}
+	return remaining, true, nil
+}
+
+// isFormatOutput reports whether path is a file that format renders to
+func isFormatOutput(format Format, path string) bool {
+	if strings.Contains(path, "..") {
+		return false
+	}
+	if format.IsSingleFile {
+		single := filepath.ToSlash(format.SingleFilePath)
+		return path == single || (format.Nested && strings.HasSuffix(path, "/"+single))
+	}
+	prefix := strings.TrimSuffix(filepath.ToSlash(format.DirectoryPrefix), "/") + "/"
+	return strings.HasPrefix(path, prefix) && strings.HasSuffix(path, format.FileExtension)
+}
+
+// removeEmptyParents removes dir and its parents while they are empty,
+// stopping at root, which is kept
+func removeEmptyParents(dir, root string) {
+	for dir != root && strings.HasPrefix(dir, root+string(os.PathSeparator)) {
+		entries, err := os.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
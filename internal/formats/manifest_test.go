@@ -0,0 +1,145 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRenderRulesToFormat_RemovesStaleFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(filepath.Join(".rules", "backend"), 0755)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "backend", "api.md"), []byte("# API\n"), 0644)
+
+	if _, err := RenderRulesToFormat(".rules", "cursor", false); err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+
+	// A file created by hand is never removed
+	handMade := filepath.Join(".cursor", "rules", "local.mdc")
+	os.WriteFile(handMade, []byte("# Local\n"), 0644)
+
+	// Rename one rule and remove the other
+	os.Rename(filepath.Join(".rules", "style.md"), filepath.Join(".rules", "code-style.md"))
+	os.RemoveAll(filepath.Join(".rules", "backend"))
+
+	result, err := RenderRulesToFormat(".rules", "cursor", false)
+	if err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+
+	expectedRemoved := []string{".cursor/rules/backend/api.mdc", ".cursor/rules/style.mdc"}
+	if !reflect.DeepEqual(result.Removed, expectedRemoved) {
+		t.Errorf("Expected %v to be removed, got %v", expectedRemoved, result.Removed)
+	}
+	for _, path := range expectedRemoved {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(".cursor", "rules", "backend")); !os.IsNotExist(err) {
+		t.Error("Expected the empty backend directory to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(".cursor", "rules", "code-style.mdc")); err != nil {
+		t.Errorf("Expected the renamed rule to be rendered: %v", err)
+	}
+	if _, err := os.Stat(handMade); err != nil {
+		t.Errorf("Expected the hand-made file to be kept: %v", err)
+	}
+
+	manifest, err := LoadManifest(".rules", "cursor")
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(manifest.Files, []string{".cursor/rules/code-style.mdc"}) {
+		t.Errorf("Unexpected manifest files %v", manifest.Files)
+	}
+}
+
+func TestRenderRulesToFormat_KeepsEditedStaleFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(".rules", 0755)
+	os.MkdirAll(filepath.Join("services", "api"), 0755)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "api.md"), []byte("---\nglobs: services/api/**\n---\n\n# API\n"), 0644)
+
+	for _, format := range []string{"cursor", "agents"} {
+		if _, err := RenderRulesToFormat(".rules", format, false); err != nil {
+			t.Fatalf("RenderRulesToFormat(%s) failed: %v", format, err)
+		}
+	}
+
+	// Edit the rendered files, then remove their rules
+	edited := filepath.Join(".cursor", "rules", "style.mdc")
+	os.WriteFile(edited, []byte("# Style, edited by hand\n"), 0644)
+	nested := filepath.Join("services", "api", "AGENTS.md")
+	content, _ := os.ReadFile(nested)
+	os.WriteFile(nested, []byte(strings.Replace(string(content), "# API", "# API, edited by hand", 1)), 0644)
+	os.Remove(filepath.Join(".rules", "style.md"))
+	os.Remove(filepath.Join(".rules", "api.md"))
+
+	for format, expected := range map[string]string{"cursor": ".cursor/rules/style.mdc", "agents": "services/api/AGENTS.md"} {
+		changes, err := DiffRender(".rules", format)
+		if err != nil {
+			t.Fatalf("DiffRender(%s) failed: %v", format, err)
+		}
+		for _, change := range changes {
+			if change.Path == expected {
+				t.Errorf("Expected edited %s not to be reported as a change", expected)
+			}
+		}
+
+		result, err := RenderRulesToFormat(".rules", format, false)
+		if err != nil {
+			t.Fatalf("RenderRulesToFormat(%s) failed: %v", format, err)
+		}
+		if slices.Contains(result.Removed, expected) || !reflect.DeepEqual(result.Kept, []string{expected}) {
+			t.Errorf("Expected %s to be kept, got removed %v and kept %v", expected, result.Removed, result.Kept)
+		}
+		if _, err := os.Stat(filepath.FromSlash(expected)); err != nil {
+			t.Errorf("Expected %s to be left alone: %v", expected, err)
+		}
+
+		// The kept file is no longer managed
+		manifest, err := LoadManifest(".rules", format)
+		if err != nil {
+			t.Fatalf("LoadManifest failed: %v", err)
+		}
+		if slices.Contains(manifest.Files, expected) {
+			t.Errorf("Expected %s to be dropped from the manifest", expected)
+		}
+	}
+}
+
+func TestRemoveStaleFiles_OnlyFormatOutput(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.WriteFile("main.go", []byte("package main\n"), 0644)
+	os.MkdirAll(filepath.Join(".cursor", "rules"), 0755)
+	os.WriteFile(filepath.Join(".cursor", "rules", "notes.txt"), []byte("notes\n"), 0644)
+
+	previous := Manifest{Format: "cursor", Files: []string{"main.go", ".cursor/rules/notes.txt", ".cursor/rules/../../main.go"}}
+	removed, _, err := removeStaleFiles(GetFormat("cursor"), previous, nil)
+	if err != nil {
+		t.Fatalf("removeStaleFiles failed: %v", err)
+	}
+	if len(removed) != 0 {
+		t.Errorf("Expected nothing to be removed, got %v", removed)
+	}
+}
+
+func TestLoadManifest_Missing(t *testing.T) {
+	manifest, err := LoadManifest(t.TempDir(), "cursor")
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if manifest.Format != "cursor" || len(manifest.Files) != 0 {
+		t.Errorf("Expected an empty manifest, got %+v", manifest)
+	}
+}
@@ -0,0 +1,105 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRenderRulesToFormat_NestedAgents(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(".rules", 0755)
+	os.MkdirAll(filepath.Join("services", "api"), 0755)
+	os.MkdirAll("web", 0755)
+	rules := map[string]string{
+		"style.md":   "---\nalwaysApply: true\n---\n\n# Style\n",
+		"api.md":     "---\nglobs: services/api/**\nalwaysApply: false\n---\n\n# API\n",
+		"web.md":     "---\nglobs: [\"web/**/*.tsx\", \"web/*.ts\"]\n---\n\n# Web\n",
+		"missing.md": "---\nglobs: docs/**\n---\n\n# Docs\n",
+		"go.md":      "---\nglobs: \"**/*.go\"\n---\n\n# Go\n",
+		"routes.md":  "---\nglobs: \"services/api/routes/**, services/api/*.go\"\n---\n\n# Routes\n",
+	}
+	for name, content := range rules {
+		os.WriteFile(filepath.Join(".rules", name), []byte(content), 0644)
+	}
+	os.WriteFile(filepath.Join("web", "AGENTS.md"), []byte("# Web notes\n"), 0644)
+
+	result, err := RenderRulesToFormat(".rules", "agents", false)
+	if err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+	if expected := []string{"AGENTS.md", "services/api/AGENTS.md", "web/AGENTS.md"}; !reflect.DeepEqual(result.Rendered, expected) {
+		t.Fatalf("Expected %v to be rendered, got %v", expected, result.Rendered)
+	}
+
+	root, _ := os.ReadFile("AGENTS.md")
+	api, _ := os.ReadFile(filepath.Join("services", "api", "AGENTS.md"))
+	web, _ := os.ReadFile(filepath.Join("web", "AGENTS.md"))
+	if !strings.Contains(string(root), "## Style") || strings.Contains(string(root), "## API") {
+		t.Errorf("Expected only the always applied rule in AGENTS.md:\n%s", root)
+	}
+	if !strings.Contains(string(api), "## API") || !strings.Contains(string(api), "## Routes") || strings.Contains(string(api), "## Style") {
+		t.Errorf("Expected the API rule in services/api/AGENTS.md:\n%s", api)
+	}
+	if !strings.HasPrefix(string(web), "# Web notes\n\n"+BlockBegin) || !strings.Contains(string(web), "## Web") {
+		t.Errorf("Expected the web rule in a block after the notes in web/AGENTS.md:\n%s", web)
+	}
+	for _, path := range []string{filepath.Join("docs", "AGENTS.md"), "go.md"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be rendered", path)
+		}
+	}
+
+	// Rules that are removed take their nested file, or block, with them
+	os.Remove(filepath.Join(".rules", "api.md"))
+	os.Remove(filepath.Join(".rules", "routes.md"))
+	os.Remove(filepath.Join(".rules", "web.md"))
+	result, err = RenderRulesToFormat(".rules", "agents", false)
+	if err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+	if expected := []string{"services/api/AGENTS.md", "web/AGENTS.md"}; !reflect.DeepEqual(result.Removed, expected) {
+		t.Errorf("Expected %v to be removed, got %v", expected, result.Removed)
+	}
+	if _, err := os.Stat(filepath.Join("services", "api", "AGENTS.md")); !os.IsNotExist(err) {
+		t.Error("Expected services/api/AGENTS.md to be removed")
+	}
+	if web, _ := os.ReadFile(filepath.Join("web", "AGENTS.md")); string(web) != "# Web notes\n" {
+		t.Errorf("Expected only the notes to be left in web/AGENTS.md, got %q", web)
+	}
+}
+
+func TestSplitGlobs(t *testing.T) {
+	tests := map[string][]string{
+		"src/**":                  {"src/**"},
+		"src/**, lib/**":          {"src/**", " lib/**"},
+		"src/{a,b}/**,docs/*.md":  {"src/{a,b}/**", "docs/*.md"},
+		"{src,lib}/**/*.{ts,tsx}": {"{src,lib}/**/*.{ts,tsx}"},
+	}
+	for value, expected := range tests {
+		if globs := splitGlobs(value); !reflect.DeepEqual(globs, expected) {
+			t.Errorf("splitGlobs(%q) = %q, expected %q", value, globs, expected)
+		}
+	}
+}
+
+func TestGlobRoot(t *testing.T) {
+	tests := map[string][]string{
+		"services/api/**":    {"services", "api"},
+		"./src/app/**/*.tsx": {"src", "app"},
+		"web/*.ts":           {"web"},
+		"docs/guide.md":      {"docs"},
+		"**/*.go":            nil,
+		"*.md":               nil,
+		"src/{a,b}/**":       {"src"},
+		"../outside/**":      nil,
+	}
+	for glob, expected := range tests {
+		if root := globRoot(glob); !reflect.DeepEqual(root, expected) {
+			t.Errorf("globRoot(%q) = %v, expected %v", glob, root, expected)
+		}
+	}
+}
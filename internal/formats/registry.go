@@ -0,0 +1,182 @@
+package formats
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed definitions/*.yaml
+var definitionsFS embed.FS
+
+// namePattern restricts format names, which are used in the paths of the
+// render manifests
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+
+// BuiltinSource is the Source of the formats that ship with the CLI
+const BuiltinSource = "built-in"
+
+var (
+	// builtinFormats are the embedded format definitions
+	builtinFormats = mustLoadBuiltinFormats()
+	// knownFormats are the built-in formats plus any user-defined ones
+	knownFormats = builtinFormats
+)
+
+// mustLoadBuiltinFormats parses the embedded format definitions. They are
+// part of the binary, so an invalid one is a bug.
+func mustLoadBuiltinFormats() map[string]Format {
+	entries, err := definitionsFS.ReadDir("definitions")
+	if err != nil {
+		panic(fmt.Sprintf("failed to read built-in format definitions: %v", err))
+	}
+
+	formats := make(map[string]Format)
+	for _, entry := range entries {
+		path := "definitions/" + entry.Name()
+		data, err := definitionsFS.ReadFile(path)
+		if err != nil {
+			panic(fmt.Sprintf("failed to read %s: %v", path, err))
+		}
+		format, err := parseDefinition(data, path)
+		if err != nil {
+			panic(err.Error())
+		}
+		format.Source = BuiltinSource
+		formats[format.Name] = format
+	}
+	return formats
+}
+
+// LoadFormatDefinitions reads the format definitions (*.yaml) in the user's
+// and the project's directories on top of the built-in ones. A project
+// definition replaces a user definition with the same name, and a user
+// definition replaces a built-in one. Projects can't replace built-in
+// formats, since their definitions are run by anyone who renders in the
+// project. Missing or empty directories are skipped.
+func LoadFormatDefinitions(userDir, projectDir string) error {
+	formats := make(map[string]Format, len(builtinFormats))
+	for name, format := range builtinFormats {
+		formats[name] = format
+	}
+
+	for _, dir := range []string{userDir, projectDir} {
+		if dir == "" {
+			continue
+		}
+		paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+		if err != nil {
+			return fmt.Errorf("failed to list format definitions in %s: %w", dir, err)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read format definition: %w", err)
+			}
+			format, err := parseDefinition(data, path)
+			if err != nil {
+				return err
+			}
+			if format.Name == "default" {
+				return fmt.Errorf("invalid format definition %s: the default format can't be redefined", path)
+			}
+			if _, ok := builtinFormats[format.Name]; ok && dir == projectDir {
+				return fmt.Errorf("invalid format definition %s: projects can't redefine the built-in %s format", path, format.Name)
+			}
+			format.Source = path
+			formats[format.Name] = format
+		}
+	}
+
+	knownFormats = formats
+	return nil
+}
+
+// parseDefinition parses a format definition. The name defaults to the file
+// name, and directory formats to the .md extension.
+func parseDefinition(data []byte, path string) (Format, error) {
+	var format Format
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&format); err != nil {
+		return Format{}, fmt.Errorf("invalid format definition %s: %w", path, err)
+	}
+
+	if format.Name == "" {
+		format.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if !namePattern.MatchString(format.Name) {
+		return Format{}, fmt.Errorf("invalid format definition %s: invalid name %q", path, format.Name)
+	}
+	if err := checkTargetPath("directory", format.DirectoryPrefix); err != nil {
+		return Format{}, fmt.Errorf("invalid format definition %s: %w", path, err)
+	}
+	if err := checkTargetPath("file", format.SingleFilePath); err != nil {
+		return Format{}, fmt.Errorf("invalid format definition %s: %w", path, err)
+	}
+	if strings.ContainsAny(format.FileExtension, `/\`) {
+		return Format{}, fmt.Errorf("invalid format definition %s: extension %q can't contain a path separator", path, format.FileExtension)
+	}
+	switch {
+	case format.SingleFilePath != "" && format.DirectoryPrefix != "":
+		return Format{}, fmt.Errorf("invalid format definition %s: set either file or directory, not both", path)
+	case format.Nested && format.SingleFilePath == "":
+		return Format{}, fmt.Errorf("invalid format definition %s: only single file formats can be nested", path)
+	case format.SingleFilePath != "":
+		format.IsSingleFile = true
+	case format.DirectoryPrefix == "":
+		return Format{}, fmt.Errorf("invalid format definition %s: a directory or a file is required", path)
+	case format.FileExtension == "":
+		format.FileExtension = ".md"
+	}
+	if format.Description == "" {
+		format.Description = fmt.Sprintf("%s rules", format.Name)
+	}
+
+	return format, nil
+}
+
+// checkTargetPath makes sure a path that rules are rendered to stays inside
+// the project: it must be relative and can't have ".." segments
+func checkTargetPath(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if filepath.IsAbs(value) || filepath.VolumeName(value) != "" || strings.HasPrefix(value, "/") || strings.HasPrefix(value, `\`) {
+		return fmt.Errorf("%s %q must be a relative path", field, value)
+	}
+	for _, segment := range strings.FieldsFunc(value, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if segment == ".." {
+			return fmt.Errorf("%s %q can't leave the project", field, value)
+		}
+	}
+	return nil
+}
+
+// LookupFormat returns the definition of a known format
+func LookupFormat(name string) (Format, bool) {
+	format, ok := knownFormats[name]
+	return format, ok
+}
+
+// GetAllFormats returns every format that rules can be rendered to, sorted
+// by name
+func GetAllFormats() []Format {
+	formats := make([]Format, 0, len(knownFormats))
+	for name, format := range knownFormats {
+		if name != "default" {
+			formats = append(formats, format)
+		}
+	}
+	sort.Slice(formats, func(i, j int) bool { return formats[i].Name < formats[j].Name })
+	return formats
+}
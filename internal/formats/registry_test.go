@@ -0,0 +1,134 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinFormats(t *testing.T) {
+	expected := []string{"agents", "amp", "claude", "cline", "codex", "cody", "continue", "copilot", "cursor", "windsurf"}
+
+	all := GetAllFormats()
+	if len(all) != len(expected) {
+		t.Fatalf("Expected %d formats, got %d", len(expected), len(all))
+	}
+	for i, format := range all {
+		if format.Name != expected[i] {
+			t.Errorf("Expected format %s at %d, got %s", expected[i], i, format.Name)
+		}
+		if format.Source != BuiltinSource {
+			t.Errorf("Expected %s to be built in, got %s", format.Name, format.Source)
+		}
+	}
+
+	if claude := GetFormat("claude"); !claude.IsSingleFile || claude.SingleFilePath != "CLAUDE.md" {
+		t.Errorf("Expected claude to render to CLAUDE.md, got %+v", claude)
+	}
+	if agents := GetFormat("agents"); !agents.IsSingleFile || !agents.Nested || agents.SingleFilePath != "AGENTS.md" {
+		t.Errorf("Expected agents to render to nested AGENTS.md files, got %+v", agents)
+	}
+	if unknown := GetFormat("aider"); unknown.DirectoryPrefix != ".aider/rules" || unknown.FileExtension != ".md" {
+		t.Errorf("Expected unknown formats to render to .<name>/rules, got %+v", unknown)
+	}
+}
+
+func TestLoadFormatDefinitions(t *testing.T) {
+	t.Cleanup(func() { LoadFormatDefinitions("", "") })
+
+	userDir := t.TempDir()
+	projectDir := t.TempDir()
+	os.WriteFile(filepath.Join(userDir, "aider.yaml"), []byte(`description: Aider conventions
+directory: .aider/conventions
+frontmatter:
+  fields: [scope, description]
+  rename:
+    globs: scope
+  values:
+    scope:
+      "**": everywhere
+  defaults:
+    scope: everywhere
+`), 0644)
+	// The project's definition replaces the user's
+	os.WriteFile(filepath.Join(userDir, "notes.yaml"), []byte("file: NOTES.md\n"), 0644)
+	os.WriteFile(filepath.Join(projectDir, "notes.yaml"), []byte("file: docs/NOTES.md\n"), 0644)
+
+	if err := LoadFormatDefinitions(userDir, projectDir); err != nil {
+		t.Fatalf("LoadFormatDefinitions failed: %v", err)
+	}
+
+	aider, ok := LookupFormat("aider")
+	if !ok {
+		t.Fatal("Expected aider format to be defined")
+	}
+	if aider.FileExtension != ".md" || aider.Source != filepath.Join(userDir, "aider.yaml") {
+		t.Errorf("Unexpected aider format %+v", aider)
+	}
+	if notes := GetFormat("notes"); notes.SingleFilePath != "docs/NOTES.md" {
+		t.Errorf("Expected project definition to win, got %s", notes.SingleFilePath)
+	}
+
+	rendered, err := TransformRuleContent([]byte("---\nglobs: \"**\"\ntags: [go]\ndescription: Go style\n---\n\n# Go\n"), aider)
+	if err != nil {
+		t.Fatalf("TransformRuleContent failed: %v", err)
+	}
+	for _, expected := range []string{"scope: everywhere", "description: Go style", "# Go"} {
+		if !strings.Contains(string(rendered), expected) {
+			t.Errorf("Expected %q in rendered rule:\n%s", expected, rendered)
+		}
+	}
+	if strings.Contains(string(rendered), "tags") {
+		t.Errorf("Expected tags to be dropped:\n%s", rendered)
+	}
+
+	// Loading again starts over from the built-in formats
+	if err := LoadFormatDefinitions("", ""); err != nil {
+		t.Fatalf("LoadFormatDefinitions failed: %v", err)
+	}
+	if _, ok := LookupFormat("aider"); ok {
+		t.Error("Expected aider format to be gone after reloading")
+	}
+}
+
+func TestLoadFormatDefinitionsErrors(t *testing.T) {
+	t.Cleanup(func() { LoadFormatDefinitions("", "") })
+
+	tests := []struct {
+		name       string
+		definition string
+		expected   string
+	}{
+		{"no-target.yaml", "description: Nowhere\n", "a directory or a file is required"},
+		{"both.yaml", "file: X.md\ndirectory: .x\n", "not both"},
+		{"nested.yaml", "directory: .x\nnested: true\n", "only single file formats can be nested"},
+		{"typo.yaml", "directory: .x\nextention: .md\n", "extention"},
+		{"default.yaml", "directory: .other\n", "can't be redefined"},
+		{"absolute.yaml", "file: /home/user/.bashrc\n", "must be a relative path"},
+		{"parent.yaml", "directory: .x/../../..\n", "can't leave the project"},
+		{"extension.yaml", "directory: .x\nextension: /../../x\n", "path separator"},
+		{"name.yaml", "name: ../../x\ndirectory: .x\n", "invalid name"},
+	}
+
+	for _, tt := range tests {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, tt.name), []byte(tt.definition), 0644)
+
+		err := LoadFormatDefinitions(dir, "")
+		if err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("%s: expected error containing %q, got %v", tt.name, tt.expected, err)
+		}
+	}
+
+	// Only the user can replace built-in formats
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "claude.yaml"), []byte("file: OTHER.md\n"), 0644)
+	if err := LoadFormatDefinitions(dir, ""); err != nil {
+		t.Errorf("Expected the user to be able to redefine claude, got %v", err)
+	}
+	err := LoadFormatDefinitions("", dir)
+	if err == nil || !strings.Contains(err.Error(), "projects can't redefine") {
+		t.Errorf("Expected the project redefinition of claude to fail, got %v", err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 )
 
 // RenderOptions holds configuration options for the rendering process
@@ -13,15 +15,30 @@ type RenderOptions struct {
 	Verbose      bool
 }
 
+// RenderResult describes the files changed by rendering to a target
+type RenderResult struct {
+	// Rendered are the paths of the files that were written
+	Rendered []string
+	// Removed are the paths of previously rendered files whose rule no
+	// longer exists
+	Removed []string
+	// Kept are the paths of previously rendered files whose rule no longer
+	// exists, but which were changed since they were rendered and are left
+	// alone
+	Kept []string
+}
+
 // RenderRulesToFormat renders rules from the source directory to the target format
-// This is a higher-level function that sets up the rendering process
-func RenderRulesToFormat(sourceDir string, targetFormatName string, verbose bool) error {
+// This is a higher-level function that sets up the rendering process. Files
+// rendered by a previous run whose rule was removed or renamed are deleted,
+// using the target's manifest, unless they were changed since.
+func RenderRulesToFormat(sourceDir string, targetFormatName string, verbose bool) (RenderResult, error) {
 	// Get the target format
 	targetFormat := GetFormat(targetFormatName)
 
 	// Check if source directory exists
 	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
-		return fmt.Errorf("source directory %s does not exist", sourceDir)
+		return RenderResult{}, fmt.Errorf("source directory %s does not exist", sourceDir)
 	}
 
 	// For single file formats, make sure the parent directory exists
@@ -29,16 +46,150 @@ func RenderRulesToFormat(sourceDir string, targetFormatName string, verbose bool
 		parentDir := filepath.Dir(targetFormat.SingleFilePath)
 		if parentDir != "." && parentDir != "" {
 			if err := os.MkdirAll(parentDir, 0755); err != nil {
-				return fmt.Errorf("failed to create parent directory for single file format: %w", err)
+				return RenderResult{}, fmt.Errorf("failed to create parent directory for single file format: %w", err)
 			}
 		}
 	} else {
 		// For directory-based formats, create the target directory
 		if err := os.MkdirAll(targetFormat.DirectoryPrefix, 0755); err != nil {
-			return fmt.Errorf("failed to create target directory %s: %w", targetFormat.DirectoryPrefix, err)
+			return RenderResult{}, fmt.Errorf("failed to create target directory %s: %w", targetFormat.DirectoryPrefix, err)
 		}
 	}
 
+	previous, err := LoadManifest(sourceDir, targetFormat.Name)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
 	// Process the rule files
-	return ProcessRuleFiles(sourceDir, targetFormat)
+	files, err := BuildRuleFiles(sourceDir, targetFormat)
+	if err != nil {
+		return RenderResult{}, err
+	}
+	rendered, err := files.Write()
+	if err != nil {
+		return RenderResult{}, err
+	}
+	result := RenderResult{Rendered: rendered}
+
+	result.Removed, result.Kept, err = removeStaleFiles(targetFormat, previous, rendered)
+	if err != nil {
+		return result, err
+	}
+
+	manifest := newManifest(targetFormat, files)
+	if err := manifest.Save(sourceDir); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// RenderChangedRules re-renders only the given paths in the source directory
+// to the target format, e.g. the rules that changed while watching. Rules
+// that were removed, or no longer apply to the format, have their rendered
+// file removed if the target's manifest lists it. Single file formats
+// combine all rules, so they are rendered as a whole.
+func RenderChangedRules(sourceDir string, targetFormatName string, changed []string) (RenderResult, error) {
+	targetFormat := GetFormat(targetFormatName)
+	if targetFormat.IsSingleFile {
+		return RenderRulesToFormat(sourceDir, targetFormatName, false)
+	}
+
+	manifest, err := LoadManifest(sourceDir, targetFormat.Name)
+	if err != nil {
+		return RenderResult{}, err
+	}
+
+	files := FileSet{}
+	var stale []string
+	renderPath := func(path string) error {
+		targetPath, content, ok, err := renderRuleFile(sourceDir, path, targetFormat)
+		if err != nil {
+			return err
+		}
+		if ok {
+			files[targetPath] = content
+		} else {
+			stale = append(stale, targetPath)
+		}
+		return nil
+	}
+
+	for _, path := range changed {
+		if isManifestPath(sourceDir, path) {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		switch {
+		case os.IsNotExist(err):
+			// The rule, or a directory of rules, was removed or renamed
+			targetPath, err := ruleTargetPath(sourceDir, path, targetFormat)
+			if err != nil {
+				return RenderResult{}, err
+			}
+			relPath, err := filepath.Rel(sourceDir, path)
+			if err != nil {
+				return RenderResult{}, fmt.Errorf("failed to get relative path: %w", err)
+			}
+			targetDir := filepath.ToSlash(filepath.Join(targetFormat.DirectoryPrefix, relPath))
+			for _, file := range manifest.Files {
+				if file == targetPath || strings.HasPrefix(file, targetDir+"/") {
+					stale = append(stale, file)
+				}
+			}
+		case err != nil:
+			return RenderResult{}, fmt.Errorf("failed to read %s: %w", path, err)
+		case info.IsDir():
+			// A directory of rules was added or moved in
+			err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				return renderPath(path)
+			})
+			if err != nil {
+				return RenderResult{}, err
+			}
+		default:
+			if err := renderPath(path); err != nil {
+				return RenderResult{}, err
+			}
+		}
+	}
+
+	rendered, err := files.Write()
+	if err != nil {
+		return RenderResult{}, err
+	}
+	result := RenderResult{Rendered: rendered}
+
+	// Only files the manifest lists are removed, as with a full render
+	listed := Manifest{Format: targetFormat.Name, Hashes: manifest.Hashes}
+	for _, path := range stale {
+		if slices.Contains(manifest.Files, path) {
+			listed.Files = append(listed.Files, path)
+		}
+	}
+	result.Removed, result.Kept, err = removeStaleFiles(targetFormat, listed, rendered)
+	if err != nil {
+		return result, err
+	}
+
+	updated := newManifest(targetFormat, files)
+	for _, path := range manifest.Files {
+		if slices.Contains(result.Removed, path) || slices.Contains(result.Kept, path) || slices.Contains(rendered, path) {
+			continue
+		}
+		updated.Files = append(updated.Files, path)
+		if hash, ok := manifest.Hashes[path]; ok {
+			updated.Hashes[path] = hash
+		}
+	}
+	if err := updated.Save(sourceDir); err != nil {
+		return result, err
+	}
+
+	return result, nil
 }
@@ -113,7 +113,7 @@ This is a test rule for React components.
 			os.Chdir(tmpTestDir)
 
 			// Run RenderRulesToFormat
-			err := RenderRulesToFormat(sourceDir, tt.format, false)
+			_, err := RenderRulesToFormat(sourceDir, tt.format, false)
 			if err != nil {
 				t.Fatalf("RenderRulesToFormat failed: %v", err)
 			}
@@ -227,7 +227,7 @@ This rule has no frontmatter and should be included (treated as alwaysApply: tru
 			os.Chdir(tmpTestDir)
 
 			// Run RenderRulesToFormat
-			err := RenderRulesToFormat(sourceDir, tt.format, false)
+			_, err := RenderRulesToFormat(sourceDir, tt.format, false)
 			if err != nil {
 				t.Fatalf("RenderRulesToFormat failed: %v", err)
 			}
@@ -260,9 +260,9 @@ This rule has no frontmatter and should be included (treated as alwaysApply: tru
 				t.Errorf("Single file should NOT contain manual rule")
 			}
 
-			// Verify it starts with "# Rules" header
-			if !strings.HasPrefix(contentStr, "# Rules\n") {
-				t.Errorf("Single file should start with '# Rules' header")
+			// Verify the rules are in a managed block starting with "# Rules" header
+			if !strings.HasPrefix(contentStr, BlockBegin+"\n# Rules\n") || !strings.HasSuffix(contentStr, BlockEnd+"\n") {
+				t.Errorf("Single file should be a managed block starting with '# Rules' header")
 			}
 
 			// Verify no frontmatter is included in single file formats
@@ -310,7 +310,7 @@ Rules for React components.
 	os.Chdir(tmpTestDir)
 
 	// Run RenderRulesToFormat for continue format
-	err := RenderRulesToFormat(sourceDir, "continue", false)
+	_, err := RenderRulesToFormat(sourceDir, "continue", false)
 	if err != nil {
 		t.Fatalf("RenderRulesToFormat failed: %v", err)
 	}
@@ -402,7 +402,7 @@ Test content.
 			os.Chdir(tmpTestDir)
 
 			// Run RenderRulesToFormat for windsurf
-			err := RenderRulesToFormat(sourceDir, "windsurf", false)
+			_, err := RenderRulesToFormat(sourceDir, "windsurf", false)
 			if err != nil {
 				t.Fatalf("RenderRulesToFormat failed: %v", err)
 			}
@@ -491,7 +491,7 @@ Test content.
 			os.Chdir(tmpTestDir)
 
 			// Run RenderRulesToFormat for copilot
-			err := RenderRulesToFormat(sourceDir, "copilot", false)
+			_, err := RenderRulesToFormat(sourceDir, "copilot", false)
 			if err != nil {
 				t.Fatalf("RenderRulesToFormat failed: %v", err)
 			}
@@ -531,7 +531,7 @@ func TestRenderRulesToFormat_ErrorConditions(t *testing.T) {
 		}()
 		os.Chdir(tmpTestDir)
 
-		err := RenderRulesToFormat(nonExistentDir, "continue", false)
+		_, err := RenderRulesToFormat(nonExistentDir, "continue", false)
 		if err == nil {
 			t.Errorf("Expected error for non-existent source directory, but got nil")
 		}
@@ -574,7 +574,7 @@ func TestRenderRulesToFormat_OnlyMarkdownFiles(t *testing.T) {
 	os.Chdir(tmpTestDir)
 
 	// Run RenderRulesToFormat
-	err := RenderRulesToFormat(sourceDir, "continue", false)
+	_, err := RenderRulesToFormat(sourceDir, "continue", false)
 	if err != nil {
 		t.Fatalf("RenderRulesToFormat failed: %v", err)
 	}
@@ -693,7 +693,7 @@ This is a test rule.
 			os.Chdir(tmpTestDir)
 
 			// Run RenderRulesToFormat for cursor
-			err := RenderRulesToFormat(sourceDir, "cursor", false)
+			_, err := RenderRulesToFormat(sourceDir, "cursor", false)
 			if err != nil {
 				t.Fatalf("RenderRulesToFormat failed: %v", err)
 			}
@@ -5,15 +5,64 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
-// renderToSingleFile renders all rules with alwaysApply: true to a single file
-func renderToSingleFile(sourceDir string, format Format) error {
-	var combinedContent bytes.Buffer
+// singleFileHeader starts the rules rendered to a single file
+const singleFileHeader = "# Rules\n\n"
 
-	// Add a header to the file
-	combinedContent.WriteString("# Rules\n\n")
+// buildSingleFileTargets renders the rules to the managed block of a single
+// file format, keeping the content of the file outside the block. Nested
+// formats also get a file in each directory that scoped rules are rooted in.
+func buildSingleFileTargets(sourceDir string, format Format) (FileSet, error) {
+	sections, err := buildSingleFile(sourceDir, format.Nested)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := LoadManifest(sourceDir, format.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	files := FileSet{}
+	for dir, rendered := range sections {
+		path := filepath.Join(dir, format.SingleFilePath)
+		existing, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read target file %s: %w", path, err)
+		}
+
+		// Files rendered before managed blocks existed are replaced as a
+		// whole: they are listed in the manifest or start like a rendered file
+		generated := slices.Contains(manifest.Files, filepath.ToSlash(path)) ||
+			bytes.Equal(existing, []byte(singleFileHeader)) ||
+			bytes.HasPrefix(existing, []byte(singleFileHeader+"## "))
+
+		merged, err := mergeManagedBlock(existing, rendered, generated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update %s: %w", path, err)
+		}
+		files[filepath.ToSlash(path)] = merged
+	}
+	return files, nil
+}
+
+// buildSingleFile combines all rules with alwaysApply: true into the content
+// of a single file, keyed by "" for the root directory. If nested is true,
+// rules that don't always apply but whose globs are rooted in an existing
+// directory are combined into content keyed by that directory.
+func buildSingleFile(sourceDir string, nested bool) (map[string][]byte, error) {
+	sections := map[string]*bytes.Buffer{}
+	section := func(dir string) *bytes.Buffer {
+		if sections[dir] == nil {
+			// Add a header to the file
+			sections[dir] = bytes.NewBufferString(singleFileHeader)
+		}
+		return sections[dir]
+	}
+	section("")
 
 	// Walk through all files in the source directory
 	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
@@ -37,45 +86,137 @@ func renderToSingleFile(sourceDir string, format Format) error {
 			return fmt.Errorf("failed to read file %s: %w", path, err)
 		}
 
-		// Check if the file has alwaysApply: true in the frontmatter
-		if isAlwaysApply(content) {
-			// Get the rule name from the path
-			ruleName, err := GetRuleName(path, sourceDir)
-			if err != nil {
-				return fmt.Errorf("failed to get rule name: %w", err)
+		// Rules with alwaysApply: true go to the root file, scoped rules of
+		// nested formats to the file of their directory
+		dir := ""
+		if !isAlwaysApply(content) {
+			if !nested {
+				return nil
 			}
-
-			// Try to extract a better title from the content
-			title := ExtractRuleTitle(content)
-			if title == "" {
-				// Fall back to the rule name
-				title = ruleName
+			if dir = nestedRuleDir(content); dir == "" {
+				return nil
 			}
+		}
+		combinedContent := section(dir)
 
-			// Add the rule to the combined content
-			combinedContent.WriteString(fmt.Sprintf("## %s\n\n", title))
+		// Get the rule name from the path
+		ruleName, err := GetRuleName(path, sourceDir)
+		if err != nil {
+			return fmt.Errorf("failed to get rule name: %w", err)
+		}
 
-			// Add the content without frontmatter, trimming any leading whitespace
-			ruleContent := stripFrontmatter(content)
-			// Ensure there's no trailing whitespace after each rule
-			ruleContent = bytes.TrimRight(ruleContent, " \t\n\r")
-			combinedContent.Write(ruleContent)
-			combinedContent.WriteString("\n\n")
+		// Try to extract a better title from the content
+		title := ExtractRuleTitle(content)
+		if title == "" {
+			// Fall back to the rule name
+			title = ruleName
 		}
 
+		// Add the rule to the combined content
+		combinedContent.WriteString(fmt.Sprintf("## %s\n\n", title))
+
+		// Add the content without frontmatter, trimming any leading whitespace
+		ruleContent := stripFrontmatter(content)
+		// Ensure there's no trailing whitespace after each rule
+		ruleContent = bytes.TrimRight(ruleContent, " \t\n\r")
+		combinedContent.Write(ruleContent)
+		combinedContent.WriteString("\n\n")
+
 		return nil
 	})
 
 	if err != nil {
-		return fmt.Errorf("failed to process rules: %w", err)
+		return nil, fmt.Errorf("failed to process rules: %w", err)
 	}
 
-	// Write the combined content to the target file
-	if err := os.WriteFile(format.SingleFilePath, combinedContent.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to write to target file %s: %w", format.SingleFilePath, err)
+	result := make(map[string][]byte, len(sections))
+	for dir, content := range sections {
+		result[dir] = content.Bytes()
 	}
+	return result, nil
+}
 
-	return nil
+// nestedRuleDir returns the directory that all globs of a rule are rooted
+// in, e.g. services/api for "services/api/**", or "" if the rule has no
+// globs, a glob isn't rooted in a directory, or the directory doesn't exist
+func nestedRuleDir(content []byte) string {
+	metadata, _, err := ParseFrontmatter(content)
+	if err != nil {
+		return ""
+	}
+
+	// A string may hold several comma-separated globs, e.g. "src/**, lib/**"
+	var globs []string
+	switch v := metadata["globs"].(type) {
+	case string:
+		globs = splitGlobs(v)
+	case []interface{}:
+		for _, glob := range v {
+			globs = append(globs, fmt.Sprint(glob))
+		}
+	}
+
+	var common []string
+	for i, glob := range globs {
+		root := globRoot(glob)
+		if i == 0 {
+			common = root
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(root) && common[n] == root[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	if len(common) == 0 {
+		return ""
+	}
+
+	dir := filepath.Join(common...)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+// splitGlobs splits a comma-separated list of globs, leaving commas inside
+// braces such as "src/{a,b}/**" alone
+func splitGlobs(value string) []string {
+	var globs []string
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				globs = append(globs, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(globs, value[start:])
+}
+
+// globRoot returns the leading directories of a glob that contain no
+// wildcards, e.g. [src app] for "src/app/**/*.tsx"
+func globRoot(glob string) []string {
+	glob = strings.TrimPrefix(strings.TrimSpace(glob), "./")
+	segments := strings.Split(strings.Trim(glob, "/"), "/")
+
+	var root []string
+	for _, segment := range segments[:len(segments)-1] {
+		if segment == "" || segment == "." || segment == ".." || strings.ContainsAny(segment, "*?[{") {
+			break
+		}
+		root = append(root, segment)
+	}
+	return root
 }
 
 // isAlwaysApply checks if a rule file has alwaysApply: true in the frontmatter
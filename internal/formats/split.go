@@ -0,0 +1,115 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// preambleRuleName is the rule that keeps the content before the first
+// section of a split file
+const preambleRuleName = "preamble"
+
+var (
+	sectionHeadingPattern = regexp.MustCompile(`^##\s+(.+?)\s*#*\s*$`)
+	titleHeadingPattern   = regexp.MustCompile(`^#\s+\S`)
+	nonSlugPattern        = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// fileSection is a part of a single rules file
+type fileSection struct {
+	title string
+	lines []string
+}
+
+// SplitRuleFile splits a single rules file, such as CLAUDE.md or
+// .cursorrules, into one rule per "##" section, the inverse of rendering to
+// a single file. Each rule is always applied and named after a slug of its
+// heading. Content before the first section is kept in a preamble rule,
+// unless it is only a title.
+func SplitRuleFile(path string) ([]ImportedRule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var rules []ImportedRule
+	used := map[string]int{}
+	for _, section := range splitSections(content) {
+		body := strings.TrimSpace(strings.Join(section.lines, "\n"))
+
+		name := preambleRuleName
+		if section.title == "" {
+			if body == "" || (titleHeadingPattern.MatchString(body) && !strings.Contains(body, "\n")) {
+				continue
+			}
+		} else {
+			name = slugify(section.title)
+			if !titleHeadingPattern.MatchString(body) {
+				body = strings.TrimSpace("# " + section.title + "\n\n" + body)
+			}
+		}
+
+		// Keep names unique, e.g. testing, testing-2
+		used[name]++
+		if used[name] > 1 {
+			name = fmt.Sprintf("%s-%d", name, used[name])
+		}
+
+		rules = append(rules, ImportedRule{
+			Source:  path,
+			Name:    name + ".md",
+			Content: []byte("---\nalwaysApply: true\n---\n\n" + body + "\n"),
+		})
+	}
+
+	return rules, nil
+}
+
+// splitSections splits markdown at "##" headings, ignoring headings in code
+// blocks and dropping the markers of a managed block. The first section
+// holds the content before the first heading and has no title.
+func splitSections(content []byte) []fileSection {
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	sections := []fileSection{{}}
+	fence := ""
+
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case fence == "" && isBlockMarker(line):
+			// The markers of a rendered block aren't part of any rule
+			continue
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```"):
+			fence = "```"
+		case strings.HasPrefix(trimmed, "~~~"):
+			fence = "~~~"
+		default:
+			if match := sectionHeadingPattern.FindStringSubmatch(line); match != nil {
+				sections = append(sections, fileSection{title: match[1]})
+				continue
+			}
+		}
+
+		current := &sections[len(sections)-1]
+		current.lines = append(current.lines, line)
+	}
+
+	return sections
+}
+
+// slugify turns a heading into a rule name, e.g. "Code Style (Go)" into
+// "code-style-go"
+func slugify(title string) string {
+	slug := strings.Trim(nonSlugPattern.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if slug == "" {
+		return "section"
+	}
+	return slug
+}
@@ -0,0 +1,87 @@
+package formats
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitRuleFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	content := "# Project\n\nA Go service.\n\n## Code Style\n\nUse gofmt.\n\n```md\n## Not a section\n```\n\n## Testing\n\n# Tests\n\nRun go test.\n\n## Testing\n\nTable tests.\n"
+	os.WriteFile("CLAUDE.md", []byte(content), 0644)
+
+	rules, err := SplitRuleFile("CLAUDE.md")
+	if err != nil {
+		t.Fatalf("SplitRuleFile failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"preamble.md":   "---\nalwaysApply: true\n---\n\n# Project\n\nA Go service.\n",
+		"code-style.md": "---\nalwaysApply: true\n---\n\n# Code Style\n\nUse gofmt.\n\n```md\n## Not a section\n```\n",
+		"testing.md":    "---\nalwaysApply: true\n---\n\n# Tests\n\nRun go test.\n",
+		"testing-2.md":  "---\nalwaysApply: true\n---\n\n# Testing\n\nTable tests.\n",
+	}
+	if len(rules) != len(expected) {
+		t.Fatalf("Expected %d rules, got %d", len(expected), len(rules))
+	}
+	for _, rule := range rules {
+		if want, ok := expected[rule.Name]; !ok || string(rule.Content) != want {
+			t.Errorf("Unexpected rule %s:\n%s", rule.Name, rule.Content)
+		}
+		if rule.Source != "CLAUDE.md" {
+			t.Errorf("Expected source CLAUDE.md, got %s", rule.Source)
+		}
+	}
+
+	if _, err := SplitRuleFile("missing.md"); err == nil {
+		t.Error("Expected splitting a missing file to fail")
+	}
+}
+
+func TestSplitRuleFile_RoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(".rules", 0755)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("---\nalwaysApply: true\n---\n\n# Style\n\nUse gofmt.\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "testing.md"), []byte("# Testing\n\nRun go test.\n"), 0644)
+
+	if _, err := ProcessRuleFiles(".rules", GetFormat("claude")); err != nil {
+		t.Fatalf("ProcessRuleFiles failed: %v", err)
+	}
+
+	// The "# Rules" header added when rendering isn't kept as a preamble
+	rules, err := SplitRuleFile("CLAUDE.md")
+	if err != nil {
+		t.Fatalf("SplitRuleFile failed: %v", err)
+	}
+	if len(rules) != 2 {
+		t.Fatalf("Expected 2 rules, got %d", len(rules))
+	}
+	for _, rule := range rules {
+		original, err := os.ReadFile(filepath.Join(".rules", rule.Name))
+		if err != nil {
+			t.Fatalf("Unexpected rule %s", rule.Name)
+		}
+		_, originalBody, _ := ParseFrontmatter(original)
+		_, body, _ := ParseFrontmatter(rule.Content)
+		if strings.TrimSpace(string(body)) != strings.TrimSpace(string(originalBody)) {
+			t.Errorf("Expected %s to round trip, got:\n%s", rule.Name, rule.Content)
+		}
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := map[string]string{
+		"Code Style (Go)": "code-style-go",
+		"  API & Errors ": "api-errors",
+		"日本語":             "section",
+	}
+	for title, expected := range tests {
+		if slug := slugify(title); slug != expected {
+			t.Errorf("slugify(%q) = %q, expected %q", title, slug, expected)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -79,16 +80,16 @@ func TransformRuleContent(content []byte, format Format) ([]byte, error) {
 
 	var trimmedBodyContent = bytes.TrimSpace(bodyContent)
 
-	// Handle Cursor format fallback - if no frontmatter exists or only irrelevant fields, use fallback format
-	if format.Name == "cursor" && shouldApplyCursorFallback(metadata) {
-		fallbackMetadata := RuleMetadata{
-			"description": EmptyYAMLValue{},
-			"globs":       EmptyYAMLValue{},
-			"alwaysApply": true,
+	// Formats with fallback frontmatter (like Cursor) use it for rules that
+	// have no frontmatter, or only fields the format doesn't know
+	if shouldApplyFallback(metadata, format) {
+		fallbackMetadata := RuleMetadata{}
+		for k, v := range format.Frontmatter.Fallback {
+			fallbackMetadata[k] = v
 		}
 
 		// Serialize metadata to YAML
-		metadataBytes, err := yaml.Marshal(fallbackMetadata)
+		metadataBytes, err := yaml.Marshal(cleanMetadataForYAML(fallbackMetadata))
 		if err != nil {
 			return nil, fmt.Errorf("failed to serialize fallback metadata: %w", err)
 		}
@@ -133,30 +134,27 @@ func TransformRuleContent(content []byte, format Format) ([]byte, error) {
 	return result.Bytes(), nil
 }
 
-// shouldApplyCursorFallback determines if Cursor format should apply fallback frontmatter
-// Returns true if metadata is empty OR contains only irrelevant fields (name, tags, etc.)
-func shouldApplyCursorFallback(metadata RuleMetadata) bool {
+// shouldApplyFallback determines if a format's fallback frontmatter should be used
+// Returns true if metadata is empty OR contains only fields the format doesn't keep (name, tags, etc.)
+func shouldApplyFallback(metadata RuleMetadata, format Format) bool {
+	if len(format.Frontmatter.Fallback) == 0 {
+		return false
+	}
+
 	// If completely empty, apply fallback
 	if len(metadata) == 0 {
 		return true
 	}
 
-	// Define relevant fields for Cursor format
-	relevantFields := map[string]bool{
-		"description": true,
-		"alwaysApply": true,
-		"globs":       true,
-	}
-
 	// Check if any relevant fields exist
-	for key := range metadata {
-		if relevantFields[key] {
+	for _, field := range format.Frontmatter.Fields {
+		if _, ok := metadata[field]; ok {
 			return false // Found a relevant field, don't apply fallback
 		}
 	}
 
 	// Only irrelevant fields found (like name, tags), apply fallback
-	return true
+	return len(format.Frontmatter.Fields) > 0
 }
 
 // cleanMetadataForYAML converts nil/empty values to EmptyYAMLValue for clean YAML output
@@ -195,68 +193,48 @@ func TransformMetadata(metadata RuleMetadata, format Format) (RuleMetadata, erro
 		transformed[k] = v
 	}
 
-	// Transform based on format
-	switch format.Name {
-	case "default", "continue", "cursor":
-		// Keep alwaysApply, description, and globs
-		for k := range transformed {
-			if k != "alwaysApply" && k != "description" && k != "globs" {
-				delete(transformed, k)
-			}
-		}
+	// For single file formats, we don't include any frontmatter
+	if format.IsSingleFile {
+		return RuleMetadata{}, nil
+	}
 
-	case "windsurf":
-		// Transform alwaysApply to trigger
-		if alwaysApply, ok := metadata["alwaysApply"]; ok {
-			delete(transformed, "alwaysApply")
+	mapping := format.Frontmatter
 
-			// If alwaysApply is true, set trigger to always_on
-			if alwaysApply == true {
-				transformed["trigger"] = "always_on"
-			} else {
-				// If alwaysApply is false, set trigger to manual
-				transformed["trigger"] = "manual"
-			}
-		} else {
-			// Default to manual trigger
-			transformed["trigger"] = "manual"
+	// Rename fields, e.g. globs to applyTo
+	for from, to := range mapping.Rename {
+		if value, ok := metadata[from]; ok {
+			delete(transformed, from)
+			transformed[to] = value
 		}
+	}
 
-		// Keep description and globs
-		for k := range transformed {
-			if k != "trigger" && k != "description" && k != "globs" {
-				delete(transformed, k)
-			}
+	// Translate values, e.g. alwaysApply: true to trigger: always_on
+	for field, translations := range mapping.Values {
+		value, ok := transformed[field]
+		if !ok {
+			continue
 		}
-
-	case "copilot":
-		// Transform globs to applyTo
-		if globs, ok := metadata["globs"]; ok {
-			delete(transformed, "globs")
-			transformed["applyTo"] = globs
-		} else {
-			// Default to all files
-			transformed["applyTo"] = "**"
+		if translated, ok := translations[fmt.Sprint(value)]; ok {
+			transformed[field] = translated
+		} else if defaultValue, ok := mapping.Defaults[field]; ok {
+			transformed[field] = defaultValue
 		}
+	}
 
-		// Remove other fields except description
-		for k := range transformed {
-			if k != "applyTo" && k != "description" {
-				delete(transformed, k)
-			}
+	// Fill in defaults for fields the rule doesn't set
+	for field, value := range mapping.Defaults {
+		if _, ok := transformed[field]; !ok {
+			transformed[field] = value
 		}
+	}
 
-	case "cline", "cody":
-		// For these formats, we only keep description
+	// Remove the fields the format doesn't use
+	if len(mapping.Fields) > 0 {
 		for k := range transformed {
-			if k != "description" {
+			if !slices.Contains(mapping.Fields, k) {
 				delete(transformed, k)
 			}
 		}
-
-	case "claude", "codex", "amp":
-		// For single file formats, we don't include any frontmatter
-		return RuleMetadata{}, nil
 	}
 
 	return transformed, nil
@@ -273,84 +251,113 @@ func IsRuleApplicable(content []byte, format Format) (bool, error) {
 	return true, nil
 }
 
-// ProcessRuleFiles processes all rule files in the source directory and renders them to the target format
-func ProcessRuleFiles(sourceDir string, targetFormat Format) error {
+// ProcessRuleFiles processes all rule files in the source directory and renders them to the target format.
+// It returns the paths of the rendered files.
+func ProcessRuleFiles(sourceDir string, targetFormat Format) ([]string, error) {
+	files, err := BuildRuleFiles(sourceDir, targetFormat)
+	if err != nil {
+		return nil, err
+	}
+	return files.Write()
+}
+
+// BuildRuleFiles renders all rule files in the source directory to the target
+// format in memory, without writing anything
+func BuildRuleFiles(sourceDir string, targetFormat Format) (FileSet, error) {
 	// For single file formats, we need to gather all rules with alwaysApply: true
 	if targetFormat.IsSingleFile {
-		return renderToSingleFile(sourceDir, targetFormat)
+		return buildSingleFileTargets(sourceDir, targetFormat)
 	}
 
-	// For directory-based formats, we need to create the target directory and process each file
-	targetDir := targetFormat.DirectoryPrefix
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return fmt.Errorf("failed to create target directory %s: %w", targetDir, err)
-	}
+	// For directory-based formats, each rule becomes a file in the target directory
+	files := FileSet{}
 
 	// Walk through all files in the source directory
-	return filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories
+		// Skip directories, and the manifests of rendered targets
 		if info.IsDir() {
+			if info.Name() == ManifestDirName {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		// Only process Markdown files
-		if !strings.HasSuffix(path, ".md") {
-			return nil
+		targetPath, content, ok, err := renderRuleFile(sourceDir, path, targetFormat)
+		if err != nil {
+			return err
 		}
-
-		// Skip README.md files
-		if strings.ToLower(filepath.Base(path)) == "readme.md" {
-			return nil
+		if ok {
+			files[targetPath] = content
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		// Check if the rule should be included in the target format
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return fmt.Errorf("failed to read source file %s: %w", path, err)
-		}
+	return files, nil
+}
 
-		applicable, err := IsRuleApplicable(content, targetFormat)
-		if err != nil {
-			return err
-		}
+// renderRuleFile renders a rule file in the source directory to a directory
+// based format. It returns the slash separated path of the file the rule
+// renders to and its content. ok is false if the file isn't a rule or the
+// rule isn't included in the format.
+func renderRuleFile(sourceDir, path string, targetFormat Format) (targetPath string, content []byte, ok bool, err error) {
+	targetPath, err = ruleTargetPath(sourceDir, path, targetFormat)
+	if err != nil {
+		return "", nil, false, err
+	}
 
-		if !applicable {
-			// Skip this rule
-			return nil
-		}
+	// Only process Markdown files
+	if !strings.HasSuffix(path, ".md") {
+		return targetPath, nil, false, nil
+	}
 
-		// Get relative path from source directory
-		relPath, err := filepath.Rel(sourceDir, path)
-		if err != nil {
-			return fmt.Errorf("failed to get relative path: %w", err)
-		}
+	// Skip README.md files
+	if strings.ToLower(filepath.Base(path)) == "readme.md" {
+		return targetPath, nil, false, nil
+	}
 
-		// Create target path with appropriate extension
-		targetPath := filepath.Join(targetDir, strings.TrimSuffix(relPath, ".md")+targetFormat.FileExtension)
+	// Check if the rule should be included in the target format
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to read source file %s: %w", path, err)
+	}
 
-		// Ensure target directory exists
-		targetFileDir := filepath.Dir(targetPath)
-		if err := os.MkdirAll(targetFileDir, 0755); err != nil {
-			return fmt.Errorf("failed to create target directory %s: %w", targetFileDir, err)
-		}
+	applicable, err := IsRuleApplicable(source, targetFormat)
+	if err != nil {
+		return "", nil, false, err
+	}
 
-		// Transform the content based on the format
-		transformedContent, err := TransformRuleContent(content, targetFormat)
-		if err != nil {
-			return fmt.Errorf("failed to transform content: %w", err)
-		}
+	if !applicable {
+		// Skip this rule
+		return targetPath, nil, false, nil
+	}
 
-		// Write to target file
-		if err := os.WriteFile(targetPath, transformedContent, 0644); err != nil {
-			return fmt.Errorf("failed to write to target file %s: %w", targetPath, err)
-		}
+	// Transform the content based on the format
+	content, err = TransformRuleContent(source, targetFormat)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to transform content: %w", err)
+	}
 
-		return nil
-	})
+	return targetPath, content, true, nil
+}
+
+// ruleTargetPath returns the slash separated path of the file that a rule
+// file in the source directory renders to in a directory based format
+func ruleTargetPath(sourceDir, path string, targetFormat Format) (string, error) {
+	// Get relative path from source directory
+	relPath, err := filepath.Rel(sourceDir, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	// Create target path with appropriate extension
+	return filepath.ToSlash(filepath.Join(targetFormat.DirectoryPrefix, strings.TrimSuffix(relPath, ".md")+targetFormat.FileExtension)), nil
 }
 
 // GetRuleName extracts the rule name from a file path
@@ -0,0 +1,93 @@
+package formats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// WatchRules watches the rules directory sourceDir and its subdirectories,
+// and calls onChange with the paths that changed once no further changes
+// arrived for the debounce duration, so that an editor saving several files
+// causes a single call. It returns when ctx is done or watching fails.
+func WatchRules(ctx context.Context, sourceDir string, debounce time.Duration, onChange func(paths []string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watchDirs(watcher, sourceDir); err != nil {
+		return err
+	}
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// Rendering writes the manifests, which mustn't trigger a render
+			if event.Op == fsnotify.Chmod || isManifestPath(sourceDir, event.Name) {
+				continue
+			}
+			// fsnotify doesn't watch new subdirectories by itself
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if err := watchDirs(watcher, event.Name); err != nil {
+						return err
+					}
+				}
+			}
+			pending[event.Name] = true
+			timer.Reset(debounce)
+
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("failed to watch %s: %w", sourceDir, err)
+
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			pending = map[string]bool{}
+			onChange(paths)
+		}
+	}
+}
+
+// watchDirs adds dir and its subdirectories, except the manifest directory,
+// to the watcher
+func watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ManifestDirName {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
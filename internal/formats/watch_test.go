@@ -0,0 +1,102 @@
+package formats
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRenderChangedRules(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	os.MkdirAll(filepath.Join(".rules", "backend"), 0755)
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "testing.md"), []byte("# Testing\n"), 0644)
+	os.WriteFile(filepath.Join(".rules", "backend", "api.md"), []byte("# API\n"), 0644)
+	if _, err := RenderRulesToFormat(".rules", "cursor", false); err != nil {
+		t.Fatalf("RenderRulesToFormat failed: %v", err)
+	}
+
+	// Only the changed rule is rendered again
+	os.WriteFile(filepath.Join(".rules", "style.md"), []byte("# Style\n\nUse gofmt.\n"), 0644)
+	os.WriteFile(filepath.Join(".cursor", "rules", "testing.mdc"), []byte("edited\n"), 0644)
+	result, err := RenderChangedRules(".rules", "cursor", []string{filepath.Join(".rules", "style.md")})
+	if err != nil {
+		t.Fatalf("RenderChangedRules failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Rendered, []string{".cursor/rules/style.mdc"}) || len(result.Removed) != 0 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if content, _ := os.ReadFile(filepath.Join(".cursor", "rules", "testing.mdc")); string(content) != "edited\n" {
+		t.Errorf("Expected the unchanged rule not to be rendered, got %q", content)
+	}
+
+	// Removing a directory of rules removes their rendered files
+	os.RemoveAll(filepath.Join(".rules", "backend"))
+	result, err = RenderChangedRules(".rules", "cursor", []string{filepath.Join(".rules", "backend")})
+	if err != nil {
+		t.Fatalf("RenderChangedRules failed: %v", err)
+	}
+	if !reflect.DeepEqual(result.Removed, []string{".cursor/rules/backend/api.mdc"}) {
+		t.Errorf("Expected backend/api.mdc to be removed, got %+v", result)
+	}
+
+	manifest, err := LoadManifest(".rules", "cursor")
+	if err != nil {
+		t.Fatalf("LoadManifest failed: %v", err)
+	}
+	if !reflect.DeepEqual(manifest.Files, []string{".cursor/rules/style.mdc", ".cursor/rules/testing.mdc"}) {
+		t.Errorf("Unexpected manifest files %v", manifest.Files)
+	}
+}
+
+func TestWatchRules(t *testing.T) {
+	sourceDir := t.TempDir()
+	os.MkdirAll(filepath.Join(sourceDir, ManifestDirName), 0755)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan []string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- WatchRules(ctx, sourceDir, 50*time.Millisecond, func(paths []string) {
+			changes <- paths
+		})
+	}()
+	// Give the watcher time to start
+	time.Sleep(100 * time.Millisecond)
+
+	// Writes to the manifests are ignored, and a burst of changes, including
+	// in a new subdirectory, results in a single call
+	os.WriteFile(filepath.Join(sourceDir, ManifestDirName, "cursor.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(sourceDir, "style.md"), []byte("# Style\n"), 0644)
+	os.MkdirAll(filepath.Join(sourceDir, "backend"), 0755)
+	time.Sleep(20 * time.Millisecond)
+	os.WriteFile(filepath.Join(sourceDir, "backend", "api.md"), []byte("# API\n"), 0644)
+
+	select {
+	case paths := <-changes:
+		for _, expected := range []string{filepath.Join(sourceDir, "style.md"), filepath.Join(sourceDir, "backend")} {
+			if !slices.Contains(paths, expected) {
+				t.Errorf("Expected %s in changed paths %v", expected, paths)
+			}
+		}
+		for _, path := range paths {
+			if isManifestPath(sourceDir, path) {
+				t.Errorf("Expected manifest changes to be ignored, got %v", paths)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for changes")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("WatchRules failed: %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package pack
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFileName is the file that excludes paths from a package
+const IgnoreFileName = ".rulesignore"
+
+// pattern is a single compiled gitignore-style pattern
+type pattern struct {
+	source  string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// Matcher matches slash-separated relative paths against gitignore-style
+// patterns. Later patterns take precedence over earlier ones, and a
+// pattern prefixed with "!" re-includes a path excluded by an earlier one.
+type Matcher struct {
+	patterns []pattern
+}
+
+// NewMatcher compiles a list of gitignore-style patterns. Blank lines and
+// lines starting with "#" are ignored.
+func NewMatcher(lines []string) *Matcher {
+	m := &Matcher{}
+	for _, line := range lines {
+		if p, ok := compilePattern(line); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+	return m
+}
+
+// LoadIgnoreFile reads patterns from an ignore file. A missing file yields
+// an empty matcher.
+func LoadIgnoreFile(path string) (*Matcher, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return NewMatcher(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewMatcher(lines), nil
+}
+
+// Match reports whether path is matched, and by which pattern. isDir tells
+// whether path is a directory, for patterns ending in "/".
+func (m *Matcher) Match(path string, isDir bool) (bool, string) {
+	matched := false
+	source := ""
+	for _, p := range m.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(path) {
+			matched = !p.negate
+			source = p.source
+		}
+	}
+	return matched, source
+}
+
+// MatchWithParents is like Match, but a file also matches when one of its
+// parent directories does
+func (m *Matcher) MatchWithParents(path string) (bool, string) {
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		if matched, source := m.Match(strings.Join(parts[:i], "/"), true); matched {
+			return true, source
+		}
+	}
+	return m.Match(path, false)
+}
+
+// compilePattern converts a single gitignore line into a pattern
+func compilePattern(line string) (pattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	p := pattern{source: line}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// Escaped leading "!" or "#"
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+
+	// A slash anywhere but the end anchors the pattern to the root
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return pattern{}, false
+	}
+
+	expr := globToRegexp(line)
+	if anchored {
+		expr = "^" + expr + "$"
+	} else {
+		expr = "^(?:.*/)?" + expr + "$"
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return pattern{}, false
+	}
+	p.re = re
+	return p, true
+}
+
+// globToRegexp translates gitignore glob syntax into a regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta(string(c)))
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
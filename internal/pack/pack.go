@@ -0,0 +1,336 @@
+package pack
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"rules-cli/internal/formats"
+)
+
+// File describes a file included in a package archive
+type File struct {
+	Path string // Slash-separated path relative to the package root
+	Size int64
+}
+
+// Exclusion records a file or directory left out of a package and why
+type Exclusion struct {
+	Path   string // Slash-separated path; directories end in "/"
+	Reason string
+}
+
+// alwaysExcludedDirs are never packaged, whatever the allowlist says
+var alwaysExcludedDirs = map[string]string{
+	"node_modules": "dependency directory",
+	"vendor":       "dependency directory",
+}
+
+// Collect returns the files under sourceDir that belong in a package, along
+// with everything that was left out and why.
+//
+// If allowlist (the "files" field of rules.json) is empty, only rules.json,
+// README and LICENSE files and rule markdown are included. Markdown counts
+// as a rule at the package root, under a top-level rules/ directory, or
+// anywhere if it starts with frontmatter. Patterns in
+// .rulesignore are applied on top, with gitignore semantics. rules.json is
+// always included.
+func Collect(sourceDir string, allowlist []string) ([]File, []Exclusion, error) {
+	ignore, err := LoadIgnoreFile(filepath.Join(sourceDir, IgnoreFileName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", IgnoreFileName, err)
+	}
+
+	var allow *Matcher
+	if len(allowlist) > 0 {
+		allow = NewMatcher(allowlist)
+	}
+
+	renderedOutputs := renderedOutputPaths()
+
+	var files []File
+	var excluded []Exclusion
+
+	err = filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		// Get relative path from source directory
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if info.IsDir() {
+			if reason := excludedDirReason(relPath, info.Name(), ignore); reason != "" {
+				excluded = append(excluded, Exclusion{Path: relPath + "/", Reason: reason})
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if reason := excludedFileReason(path, relPath, info.Name(), allow, ignore, renderedOutputs); reason != "" {
+			excluded = append(excluded, Exclusion{Path: relPath, Reason: reason})
+			return nil
+		}
+
+		files = append(files, File{Path: relPath, Size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to collect package files: %w", err)
+	}
+
+	return files, excluded, nil
+}
+
+// excludedDirReason returns why a directory is left out, or "" to descend into it
+func excludedDirReason(relPath, name string, ignore *Matcher) string {
+	if strings.HasPrefix(name, ".") {
+		return "hidden directory"
+	}
+	if reason, ok := alwaysExcludedDirs[name]; ok {
+		return reason
+	}
+	if matched, source := ignore.Match(relPath, true); matched {
+		return fmt.Sprintf("matches %s pattern '%s'", IgnoreFileName, source)
+	}
+	return ""
+}
+
+// excludedFileReason returns why a file is left out, or "" to include it
+func excludedFileReason(filePath, relPath, name string, allow, ignore *Matcher, renderedOutputs renderedOutputs) string {
+	// rules.json is what makes this a package
+	if relPath == "rules.json" {
+		return ""
+	}
+
+	if strings.HasPrefix(name, ".") {
+		return "hidden file"
+	}
+
+	if matched, source := ignore.Match(relPath, false); matched {
+		return fmt.Sprintf("matches %s pattern '%s'", IgnoreFileName, source)
+	}
+
+	if strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, "~") {
+		return "temporary file"
+	}
+
+	if strings.HasSuffix(name, ".zip") {
+		return "package archive"
+	}
+
+	if allow != nil {
+		if matched, _ := allow.MatchWithParents(relPath); !matched {
+			return "not listed in rules.json files"
+		}
+		return ""
+	}
+
+	if format, ok := renderedOutputs.format(relPath); ok {
+		return fmt.Sprintf("rendered output for %s", format)
+	}
+
+	if isDefaultPackageFile(filePath, relPath, name) {
+		return ""
+	}
+
+	return "not a rule, README or LICENSE; list it in rules.json files to include it"
+}
+
+// isDefaultPackageFile reports whether a file is included when rules.json has
+// no files allowlist: top-level README and LICENSE files, and rule markdown
+func isDefaultPackageFile(filePath, relPath, name string) bool {
+	topLevel := !strings.Contains(relPath, "/")
+	if topLevel {
+		upper := strings.ToUpper(name)
+		if strings.HasPrefix(upper, "README") || strings.HasPrefix(upper, "LICENSE") || strings.HasPrefix(upper, "LICENCE") {
+			return true
+		}
+	}
+
+	if !strings.HasSuffix(name, ".md") {
+		return false
+	}
+
+	// Markdown in other directories is usually documentation or examples,
+	// so it only counts as a rule if it has frontmatter
+	if topLevel || strings.HasPrefix(relPath, "rules/") {
+		return true
+	}
+	return startsWithFrontmatter(filePath)
+}
+
+// startsWithFrontmatter reports whether the file at filePath opens with a
+// frontmatter delimiter
+func startsWithFrontmatter(filePath string) bool {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	return scanner.Scan() && strings.TrimSpace(scanner.Text()) == "---"
+}
+
+// renderedOutputs records the single files written by 'rules render', which
+// are markdown but not rules
+type renderedOutputs struct {
+	paths  map[string]string // Top-level single files by path
+	nested map[string]string // Per-directory single files by file name
+}
+
+// renderedOutputPaths returns the single files of every known format
+func renderedOutputPaths() renderedOutputs {
+	outputs := renderedOutputs{
+		paths:  make(map[string]string),
+		nested: make(map[string]string),
+	}
+	for _, format := range formats.GetAllFormats() {
+		if !format.IsSingleFile {
+			continue
+		}
+		if _, ok := outputs.paths[format.SingleFilePath]; !ok {
+			outputs.paths[format.SingleFilePath] = format.Name
+		}
+		if format.Nested {
+			name := path.Base(format.SingleFilePath)
+			if _, ok := outputs.nested[name]; !ok {
+				outputs.nested[name] = format.Name
+			}
+		}
+	}
+	return outputs
+}
+
+// format returns the format that renders the file at relPath, if any
+func (o renderedOutputs) format(relPath string) (string, bool) {
+	if name, ok := o.paths[relPath]; ok {
+		return name, true
+	}
+	name, ok := o.nested[path.Base(relPath)]
+	return name, ok
+}
+
+// WriteArchive writes the given files from sourceDir into a zip archive at zipPath
+func WriteArchive(sourceDir string, files []File, zipPath string) error {
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create zip file: %w", err)
+	}
+	defer zipFile.Close()
+
+	zipWriter := zip.NewWriter(zipFile)
+
+	for _, file := range files {
+		if err := addFile(zipWriter, sourceDir, file.Path); err != nil {
+			zipWriter.Close()
+			return fmt.Errorf("failed to add %s to package: %w", file.Path, err)
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("failed to finish zip file: %w", err)
+	}
+
+	return zipFile.Close()
+}
+
+// addFile copies a single file into the zip archive
+func addFile(zipWriter *zip.Writer, sourceDir, relPath string) error {
+	sourceFile, err := os.Open(filepath.Join(sourceDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	zipFileWriter, err := zipWriter.Create(relPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(zipFileWriter, sourceFile)
+	return err
+}
+
+// ListArchive returns the files contained in a package archive
+func ListArchive(zipPath string) ([]File, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package %s: %w", zipPath, err)
+	}
+	defer reader.Close()
+
+	var files []File
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, File{Path: file.Name, Size: int64(file.UncompressedSize64)})
+	}
+
+	return files, nil
+}
+
+// ReadArchiveFile reads a single file from a package archive
+func ReadArchiveFile(zipPath, name string) ([]byte, error) {
+	reader, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open package %s: %w", zipPath, err)
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Name != name {
+			continue
+		}
+		src, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s in package: %w", name, err)
+		}
+		defer src.Close()
+		return io.ReadAll(src)
+	}
+
+	return nil, fmt.Errorf("%s not found in package %s", name, zipPath)
+}
+
+// ArchiveName returns the file name for a packed ruleset, following the
+// registry's <rule-slug>-<version>.zip convention
+func ArchiveName(name, version string) string {
+	slug := name[strings.LastIndex(name, "/")+1:]
+	return fmt.Sprintf("%s-%s.zip", slug, version)
+}
+
+// TotalSize returns the combined size of the files
+func TotalSize(files []File) int64 {
+	var total int64
+	for _, file := range files {
+		total += file.Size
+	}
+	return total
+}
+
+// FormatSize formats a byte count for display
+func FormatSize(size int64) string {
+	switch {
+	case size < 1000:
+		return fmt.Sprintf("%d B", size)
+	case size < 1000*1000:
+		return fmt.Sprintf("%.1f kB", float64(size)/1000)
+	default:
+		return fmt.Sprintf("%.1f MB", float64(size)/(1000*1000))
+	}
+}
@@ -0,0 +1,235 @@
+package pack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the given files under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+}
+
+// paths returns the paths of the files
+func paths(files []File) []string {
+	var result []string
+	for _, file := range files {
+		result = append(result, file.Path)
+	}
+	return result
+}
+
+func TestCollectAndWriteArchive(t *testing.T) {
+	sourceDir := t.TempDir()
+	files := map[string]string{
+		"rules.json":        `{"name": "acme/style", "version": "1.0.0"}`,
+		"style.md":          "# Style",
+		"nested/api.md":     "---\nglobs: api/**\n---\n# API",
+		".env":              "SECRET=1",
+		".git/HEAD":         "ref: refs/heads/main",
+		"notes.tmp":         "scratch",
+		"style-0.9.0.zip":   "old package",
+		"nested/.hidden.md": "hidden",
+	}
+	writeFiles(t, sourceDir, files)
+
+	collected, _, err := Collect(sourceDir, nil)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	expected := []string{"nested/api.md", "rules.json", "style.md"}
+	if len(collected) != len(expected) {
+		t.Fatalf("Expected %d files, got %d: %v", len(expected), len(collected), collected)
+	}
+	for i, file := range collected {
+		if file.Path != expected[i] {
+			t.Errorf("Expected file %d to be %s, got %s", i, expected[i], file.Path)
+		}
+		if file.Size != int64(len(files[file.Path])) {
+			t.Errorf("Expected %s to have size %d, got %d", file.Path, len(files[file.Path]), file.Size)
+		}
+	}
+
+	zipPath := filepath.Join(t.TempDir(), ArchiveName("acme/style", "1.0.0"))
+	if filepath.Base(zipPath) != "style-1.0.0.zip" {
+		t.Errorf("Unexpected archive name %s", filepath.Base(zipPath))
+	}
+
+	if err := WriteArchive(sourceDir, collected, zipPath); err != nil {
+		t.Fatalf("WriteArchive failed: %v", err)
+	}
+
+	listed, err := ListArchive(zipPath)
+	if err != nil {
+		t.Fatalf("ListArchive failed: %v", err)
+	}
+	if len(listed) != len(collected) {
+		t.Fatalf("Expected %d files in archive, got %d", len(collected), len(listed))
+	}
+	if TotalSize(listed) != TotalSize(collected) {
+		t.Errorf("Archive size %d does not match collected size %d", TotalSize(listed), TotalSize(collected))
+	}
+
+	content, err := ReadArchiveFile(zipPath, "rules.json")
+	if err != nil {
+		t.Fatalf("ReadArchiveFile failed: %v", err)
+	}
+	if string(content) != files["rules.json"] {
+		t.Errorf("Unexpected rules.json content: %s", content)
+	}
+
+	if _, err := ReadArchiveFile(zipPath, "missing.md"); err == nil {
+		t.Error("Expected error reading missing file from archive")
+	}
+}
+
+func TestCollectDefaults(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeFiles(t, sourceDir, map[string]string{
+		"rules.json":                "{}",
+		"README.md":                 "# Readme",
+		"LICENSE":                   "MIT",
+		"style.md":                  "# Style",
+		"rules/api.md":              "# API",
+		"docs/guide.md":             "# Guide",
+		"docs/testing.md":           "---\nalwaysApply: true\n---\n# Testing",
+		"CLAUDE.md":                 "# Rendered",
+		"services/api/AGENTS.md":    "---\nalwaysApply: true\n---\n# Rendered",
+		"main.go":                   "package main",
+		"node_modules/pkg/index.md": "# Dependency",
+		".env":                      "SECRET=1",
+	})
+
+	files, excluded, err := Collect(sourceDir, nil)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	expected := "LICENSE,README.md,docs/testing.md,rules/api.md,rules.json,style.md"
+	if got := strings.Join(paths(files), ","); got != expected {
+		t.Errorf("Expected files %s, got %s", expected, got)
+	}
+
+	reasons := map[string]string{}
+	for _, exclusion := range excluded {
+		reasons[exclusion.Path] = exclusion.Reason
+	}
+
+	expectedReasons := map[string]string{
+		".env":                   "hidden file",
+		"CLAUDE.md":              "rendered output for claude",
+		"services/api/AGENTS.md": "rendered output for agents",
+		"node_modules/":          "dependency directory",
+		"main.go":                "not a rule",
+		"docs/guide.md":          "not a rule",
+	}
+	for path, reason := range expectedReasons {
+		if !strings.HasPrefix(reasons[path], reason) {
+			t.Errorf("Expected %s to be excluded as %q, got %q", path, reason, reasons[path])
+		}
+	}
+}
+
+func TestCollectAllowlistAndIgnore(t *testing.T) {
+	sourceDir := t.TempDir()
+	writeFiles(t, sourceDir, map[string]string{
+		"rules.json":         "{}",
+		"README.md":          "# Readme",
+		"rules/style.md":     "# Style",
+		"rules/draft.md":     "# Draft",
+		"rules/wip/next.md":  "# Next",
+		"examples/sample.ts": "const x = 1",
+		"notes.md":           "# Notes",
+		".rulesignore":       "# drafts stay local\ndraft.md\nwip/\n",
+	})
+
+	files, excluded, err := Collect(sourceDir, []string{"rules/", "examples/*.ts"})
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+
+	expected := "examples/sample.ts,rules/style.md,rules.json"
+	if got := strings.Join(paths(files), ","); got != expected {
+		t.Errorf("Expected files %s, got %s", expected, got)
+	}
+
+	reasons := map[string]string{}
+	for _, exclusion := range excluded {
+		reasons[exclusion.Path] = exclusion.Reason
+	}
+	if reasons["rules/draft.md"] != "matches .rulesignore pattern 'draft.md'" {
+		t.Errorf("Unexpected reason for rules/draft.md: %q", reasons["rules/draft.md"])
+	}
+	if reasons["rules/wip/"] != "matches .rulesignore pattern 'wip/'" {
+		t.Errorf("Unexpected reason for rules/wip/: %q", reasons["rules/wip/"])
+	}
+	if reasons["notes.md"] != "not listed in rules.json files" {
+		t.Errorf("Unexpected reason for notes.md: %q", reasons["notes.md"])
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m := NewMatcher([]string{
+		"# comment",
+		"",
+		"*.log",
+		"!keep.log",
+		"/build",
+		"docs/**/*.png",
+		"tmp/",
+	})
+
+	tests := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"debug.log", false, true},
+		{"nested/debug.log", false, true},
+		{"keep.log", false, false},
+		{"build", true, true},
+		{"nested/build", true, false},
+		{"docs/a/b/c.png", false, true},
+		{"docs/c.png", false, true},
+		{"images/c.png", false, false},
+		{"tmp", true, true},
+		{"tmp", false, false},
+		{"style.md", false, false},
+	}
+
+	for _, tt := range tests {
+		if got, _ := m.Match(tt.path, tt.isDir); got != tt.expected {
+			t.Errorf("Match(%q, %v) = %v, expected %v", tt.path, tt.isDir, got, tt.expected)
+		}
+	}
+
+	if matched, source := m.MatchWithParents("tmp/cache/file.txt"); !matched || source != "tmp/" {
+		t.Errorf("Expected file under tmp/ to match via its parent, got %v %q", matched, source)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	tests := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{999, "999 B"},
+		{1500, "1.5 kB"},
+		{2500000, "2.5 MB"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatSize(tt.size); got != tt.expected {
+			t.Errorf("FormatSize(%d) = %s, expected %s", tt.size, got, tt.expected)
+		}
+	}
+}
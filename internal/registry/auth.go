@@ -2,6 +2,7 @@ package registry
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/fatih/color"
 
@@ -17,13 +18,44 @@ func InitClientWithAuth(baseURL string) (*Client, error) {
 
 	// If we have a valid token, use it
 	if authConfig.AccessToken != "" {
-		client.SetAuthToken(authConfig.AccessToken)
+		client.UseSession("", authConfig)
 		return client, nil
 	}
 
 	return client, nil
 }
 
+// UseSession authenticates the client with the session saved for
+// registryURL ("" for the default registry), which is refreshed when it
+// expires if it has a refresh token
+func (c *Client) UseSession(registryURL string, authConfig auth.AuthConfig) {
+	c.SetAuthToken(authConfig.AccessToken)
+	c.RefreshAuth = SessionRefresher(registryURL, authConfig)
+	if authConfig.ExpiresAt > 0 {
+		c.SetTokenExpiry(time.UnixMilli(authConfig.ExpiresAt))
+	}
+}
+
+// SessionRefresher returns a RefreshFunc that exchanges the session's refresh
+// token for a new access token and saves the new session for registryURL,
+// or nil if the session has no refresh token (e.g. CONTINUE_API_KEY is used)
+func SessionRefresher(registryURL string, authConfig auth.AuthConfig) RefreshFunc {
+	if authConfig.RefreshToken == "" {
+		return nil
+	}
+
+	return func() (string, time.Time, error) {
+		refreshed, err := auth.RefreshTokenForRegistry(registryURL, authConfig.RefreshToken)
+		if err != nil {
+			return "", time.Time{}, err
+		}
+
+		// Refresh tokens are rotated, so keep the new one for next time
+		authConfig = refreshed
+		return refreshed.AccessToken, time.UnixMilli(refreshed.ExpiresAt), nil
+	}
+}
+
 // EnsureClientAuth ensures the client has authentication
 // Returns a boolean indicating if authentication was successful
 func EnsureClientAuth(client *Client, requireAuth bool) (bool, error) {
@@ -44,7 +76,7 @@ func EnsureClientAuth(client *Client, requireAuth bool) (bool, error) {
 	}
 
 	// Set the token on the client
-	client.SetAuthToken(authConfig.AccessToken)
+	client.UseSession("", authConfig)
 	return true, nil
 }
 
@@ -2,24 +2,47 @@ package registry
 
 import (
 	"archive/zip"
-	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"rules-cli/internal/utils"
 	"strings"
+	"time"
 )
 
+// DefaultGitHubBaseURL is the GitHub API used for gh: rules
+const DefaultGitHubBaseURL = "https://api.github.com"
+
+// Headers carrying a package's signature on download
+const (
+	SignatureHeader      = "X-Rules-Signature"
+	KeyFingerprintHeader = "X-Rules-Key-Fingerprint"
+)
+
+// VerifyFunc checks a downloaded package archive before it is extracted.
+// signature and fingerprint are empty for unsigned packages.
+type VerifyFunc func(name string, archive io.Reader, signature, fingerprint string) error
+
 // Client represents a registry client
 type Client struct {
-	BaseURL    string
-	AuthToken  string
-	IsLoggedIn bool
+	BaseURL       string
+	GitHubBaseURL string
+	AuthToken     string
+	IsLoggedIn    bool
+	// TokenExpiresAt is when AuthToken expires, or zero if unknown
+	TokenExpiresAt time.Time
+	// RefreshAuth, if set, is used to refresh AuthToken shortly before it
+	// expires or when the registry rejects it
+	RefreshAuth RefreshFunc
+	// VerifyPackage, if set, is called with every downloaded archive and
+	// aborts the download if it returns an error
+	VerifyPackage VerifyFunc
+	// Progress, if set, is called as package archives are downloaded and uploaded
+	Progress ProgressFunc
 }
 
 // RuleInfo contains information about a rule in the registry
@@ -33,6 +56,10 @@ type RuleInfo struct {
 // PublishMetadata represents the metadata for publishing a rule
 type PublishMetadata struct {
 	Visibility string `json:"visibility"`
+	// Signature is the base64 encoded ed25519 signature of the package archive
+	Signature string `json:"signature,omitempty"`
+	// KeyFingerprint identifies the key the package was signed with
+	KeyFingerprint string `json:"keyFingerprint,omitempty"`
 }
 
 // UserInfo represents user information from the registry
@@ -40,15 +67,27 @@ type UserInfo struct {
 	ID       string `json:"id"`
 	Email    string `json:"email"`
 	Username string `json:"username"`
-	OrgSlug  string `json:"orgSlug"`
+	// OrgSlug is the user's organization, returned by registries that
+	// don't list orgs; see Organizations
+	OrgSlug string    `json:"orgSlug"`
+	Orgs    []OrgInfo `json:"orgs"`
+}
+
+// OrgInfo describes an organization the user belongs to
+type OrgInfo struct {
+	Slug       string `json:"slug"`
+	Name       string `json:"name"`
+	Role       string `json:"role"`
+	CanPublish bool   `json:"canPublish"`
 }
 
 // NewClient creates a new registry client
 func NewClient(baseURL string) *Client {
 	return &Client{
-		BaseURL:    baseURL,
-		AuthToken:  "",
-		IsLoggedIn: false,
+		BaseURL:       baseURL,
+		GitHubBaseURL: DefaultGitHubBaseURL,
+		AuthToken:     "",
+		IsLoggedIn:    false,
 	}
 }
 
@@ -71,20 +110,14 @@ func (c *Client) DownloadRule(ownerSlug, ruleSlug, version, formatDir string) er
 		url = fmt.Sprintf("%s/v0/%s/%s/%s/download", c.BaseURL, ownerSlug, ruleSlug, version)
 	}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Add auth header if logged in
-	if c.IsLoggedIn {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.AuthToken))
-	}
-
-	utils.SetUserAgent(req)
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	// The auth header is added if logged in
+	resp, err := c.doAuthenticated(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to request rule from registry API: %w", err)
 	}
@@ -94,14 +127,20 @@ func (c *Client) DownloadRule(ownerSlug, ruleSlug, version, formatDir string) er
 		return fmt.Errorf("failed to fetch rule from registry API: status %d", resp.StatusCode)
 	}
 
-	// Read the zip file
-	zipData, err := ioutil.ReadAll(resp.Body)
+	// Stream the zip file to disk rather than holding it in memory
+	zipFile, zipSize, err := c.downloadToTempFile(resp)
 	if err != nil {
-		return fmt.Errorf("failed to read zip data: %w", err)
+		return err
+	}
+	defer removeTempFile(zipFile)
+
+	name := ownerSlug + "/" + ruleSlug
+	if err := c.verifyDownload(name, zipFile, zipSize, resp.Header.Get(SignatureHeader), resp.Header.Get(KeyFingerprintHeader)); err != nil {
+		return err
 	}
 
 	// Create a reader for the zip file
-	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	zipReader, err := zip.NewReader(zipFile, zipSize)
 	if err != nil {
 		return fmt.Errorf("failed to parse zip archive: %w", err)
 	}
@@ -157,61 +196,42 @@ func (c *Client) DownloadRule(ownerSlug, ruleSlug, version, formatDir string) er
 
 // PublishRule publishes a new version of a rule to the registry
 func (c *Client) PublishRule(ruleSlug, version, zipFilePath string, visibility string) error {
+	return c.PublishRuleWithMetadata(ruleSlug, version, zipFilePath, PublishMetadata{Visibility: visibility})
+}
+
+// PublishRuleWithMetadata publishes a new version of a rule to the registry,
+// sending metadata such as the package signature along with the archive
+func (c *Client) PublishRuleWithMetadata(ruleSlug, version, zipFilePath string, metadata PublishMetadata) error {
 	if !c.IsLoggedIn {
 		return fmt.Errorf("you must be logged in to publish a rule")
 	}
 
 	url := fmt.Sprintf("%s/v0/%s/%s", c.BaseURL, ruleSlug, version)
 
-	// Read the zip file
-	zipData, err := ioutil.ReadFile(zipFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read zip file: %w", err)
-	}
-
-	// Create multipart form data
-	var buf bytes.Buffer
-	writer := multipart.NewWriter(&buf)
-
-	// Add the zip file
-	fileWriter, err := writer.CreateFormFile("file", filepath.Base(zipFilePath))
-	if err != nil {
-		return fmt.Errorf("failed to create form file: %w", err)
-	}
-
-	if _, err := fileWriter.Write(zipData); err != nil {
-		return fmt.Errorf("failed to write zip data to form: %w", err)
-	}
-
-	// Add metadata
-	metadata := PublishMetadata{
-		Visibility: visibility,
-	}
-
 	metadataJSON, err := json.Marshal(metadata)
 	if err != nil {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := writer.WriteField("metadata", string(metadataJSON)); err != nil {
-		return fmt.Errorf("failed to write metadata field: %w", err)
-	}
-
-	writer.Close()
-
-	// Create request
-	req, err := http.NewRequest("POST", url, &buf)
-	if err != nil {
-		return fmt.Errorf("failed to create publish request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.AuthToken))
+	// The request is rebuilt, streaming the file again, if it is retried
+	// after refreshing the auth token
+	resp, err := c.doAuthenticated(func() (*http.Request, error) {
+		// Stream the multipart body instead of building it in memory
+		body, contentType, err := c.streamMultipartUpload(zipFilePath, metadataJSON)
+		if err != nil {
+			return nil, err
+		}
 
-	utils.SetUserAgent(req)
+		// Create request
+		req, err := http.NewRequest("POST", url, body)
+		if err != nil {
+			body.Close()
+			return nil, fmt.Errorf("failed to create publish request: %w", err)
+		}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
 	if err != nil {
 		return fmt.Errorf("failed to publish rule: %w", err)
 	}
@@ -240,7 +260,7 @@ func (c *Client) DownloadRuleFromGitHub(owner, repo, subPath, formatDir string)
 // downloadFromGitHub downloads rules from a GitHub repository
 func (c *Client) downloadFromGitHub(repoPath, subPath, formatDir string) error {
 	// Construct GitHub API URL to download zip of the main branch
-	url := fmt.Sprintf("https://api.github.com/repos/%s/zipball/main", repoPath)
+	url := fmt.Sprintf("%s/repos/%s/zipball/main", c.GitHubBaseURL, repoPath)
 
 	// Create HTTP request with appropriate headers
 	req, err := http.NewRequest("GET", url, nil)
@@ -262,14 +282,20 @@ func (c *Client) downloadFromGitHub(repoPath, subPath, formatDir string) error {
 		return fmt.Errorf("failed to download GitHub repository: status %d", resp.StatusCode)
 	}
 
-	// Read the response body
-	zipData, err := ioutil.ReadAll(resp.Body)
+	// Stream the archive to disk rather than holding it in memory
+	zipFile, zipSize, err := c.downloadToTempFile(resp)
 	if err != nil {
-		return fmt.Errorf("failed to read repository data: %w", err)
+		return err
+	}
+	defer removeTempFile(zipFile)
+
+	// GitHub archives are never signed
+	if err := c.verifyDownload("gh:"+repoPath, zipFile, zipSize, "", ""); err != nil {
+		return err
 	}
 
 	// Create a reader for the zip file
-	zipReader, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData)))
+	zipReader, err := zip.NewReader(zipFile, zipSize)
 	if err != nil {
 		return fmt.Errorf("failed to parse repository archive: %w", err)
 	}
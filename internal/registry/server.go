@@ -0,0 +1,394 @@
+package registry
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxUploadSize limits the size of a published package
+const maxUploadSize = 64 << 20
+
+// versionPattern matches the semantic versions considered when resolving
+// "latest"
+var versionPattern = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// segmentPattern restricts owner, slug and version path segments so they are
+// always safe to use as file names inside the storage directory
+var segmentPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._+-]*$`)
+
+// Server implements the registry API described in spec/registry-api.md on
+// top of a filesystem directory. Packages are stored as
+// <Root>/<owner>/<slug>/<version>.zip next to a <version>.json metadata file.
+type Server struct {
+	// Root is the directory packages are stored in
+	Root string
+	// Token, if set, is the bearer token required to publish packages and
+	// to download private ones
+	Token string
+
+	mu sync.Mutex
+}
+
+// NewServer creates a registry server storing packages in root
+func NewServer(root, token string) *Server {
+	return &Server{
+		Root:  root,
+		Token: token,
+	}
+}
+
+// ListenAndServe serves the registry API on addr with timeouts, so slow or
+// idle clients can't hold connections open forever
+func (s *Server) ListenAndServe(addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       5 * time.Minute,
+		WriteTimeout:      5 * time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+	return server.ListenAndServe()
+}
+
+// Handler returns the HTTP handler for the registry API
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v0/{owner}/{slug}/{version}/download", s.handleDownload)
+	mux.HandleFunc("POST /v0/{owner}/{slug}/{version}", s.handleUpload)
+	return mux
+}
+
+// handleDownload serves GET /v0/{owner}/{slug}/{version|latest}/download
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	owner, slug, version := r.PathValue("owner"), r.PathValue("slug"), r.PathValue("version")
+	if !validSegments(owner, slug, version) {
+		http.Error(w, "invalid package path", http.StatusBadRequest)
+		return
+	}
+
+	packageDir := filepath.Join(s.Root, owner, slug)
+
+	if version == "latest" {
+		latest, err := latestVersion(packageDir, s.authorized(r))
+		if err != nil {
+			http.Error(w, "package not found", http.StatusNotFound)
+			return
+		}
+		version = latest
+	}
+
+	metadata, err := readMetadata(filepath.Join(packageDir, version+".json"))
+	if err != nil {
+		http.Error(w, "package not found", http.StatusNotFound)
+		return
+	}
+
+	if metadata.Visibility == "private" && !s.authorized(r) {
+		// Don't reveal that a private package exists
+		http.Error(w, "package not found", http.StatusNotFound)
+		return
+	}
+
+	file, err := os.Open(filepath.Join(packageDir, version+".zip"))
+	if err != nil {
+		http.Error(w, "package not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "failed to read package", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	if metadata.Signature != "" {
+		w.Header().Set(SignatureHeader, metadata.Signature)
+		w.Header().Set(KeyFingerprintHeader, metadata.KeyFingerprint)
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s-%s.zip\"", slug, version))
+	w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	io.Copy(w, file)
+}
+
+// handleUpload serves POST /v0/{owner}/{slug}/{version}
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	owner, slug, version := r.PathValue("owner"), r.PathValue("slug"), r.PathValue("version")
+	if !validSegments(owner, slug, version) || version == "latest" {
+		http.Error(w, "invalid package path", http.StatusBadRequest)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	if err := r.ParseMultipartForm(maxUploadSize); err != nil {
+		http.Error(w, fmt.Sprintf("invalid upload: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "missing file field", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	zipData, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "failed to read upload", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := zip.NewReader(bytes.NewReader(zipData), int64(len(zipData))); err != nil {
+		http.Error(w, "package is not a valid zip archive", http.StatusBadRequest)
+		return
+	}
+
+	metadata := PublishMetadata{Visibility: "public"}
+	if raw := r.FormValue("metadata"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &metadata); err != nil {
+			http.Error(w, "invalid metadata", http.StatusBadRequest)
+			return
+		}
+	}
+	if metadata.Visibility != "public" && metadata.Visibility != "private" {
+		http.Error(w, "visibility must be either 'public' or 'private'", http.StatusBadRequest)
+		return
+	}
+	if (metadata.Signature == "") != (metadata.KeyFingerprint == "") {
+		http.Error(w, "signature and keyFingerprint must be sent together", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packageDir := filepath.Join(s.Root, owner, slug)
+	zipPath := filepath.Join(packageDir, version+".zip")
+	if _, err := os.Stat(zipPath); err == nil {
+		http.Error(w, fmt.Sprintf("version %s of %s/%s already exists", version, owner, slug), http.StatusConflict)
+		return
+	}
+
+	if err := os.MkdirAll(packageDir, 0755); err != nil {
+		http.Error(w, "failed to store package", http.StatusInternalServerError)
+		return
+	}
+
+	metadataJSON, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		http.Error(w, "failed to store package", http.StatusInternalServerError)
+		return
+	}
+
+	// Write both files under temporary names and rename them into place,
+	// metadata first, so a package is never visible half-written or
+	// without its metadata
+	metadataPath := filepath.Join(packageDir, version+".json")
+	if err := writeFileAtomic(metadataPath, metadataJSON); err != nil {
+		http.Error(w, "failed to store package", http.StatusInternalServerError)
+		return
+	}
+	if err := writeFileAtomic(zipPath, zipData); err != nil {
+		os.Remove(metadataPath)
+		http.Error(w, "failed to store package", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// authorized checks the bearer token on a request
+func (s *Server) authorized(r *http.Request) bool {
+	if s.Token == "" {
+		return true
+	}
+	expected := []byte("Bearer " + s.Token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// validSegments checks that every path segment is safe to use as a file name
+func validSegments(segments ...string) bool {
+	for _, segment := range segments {
+		if !segmentPattern.MatchString(segment) || strings.Contains(segment, "..") {
+			return false
+		}
+	}
+	return true
+}
+
+// readMetadata reads the metadata stored next to a package version
+func readMetadata(path string) (PublishMetadata, error) {
+	var metadata PublishMetadata
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return metadata, err
+	}
+	err = json.Unmarshal(data, &metadata)
+	return metadata, err
+}
+
+// latestVersion returns the highest release version stored in a package
+// directory. Pre-releases are skipped, as are private versions unless the
+// request is authorized.
+func latestVersion(packageDir string, authorized bool) (string, error) {
+	entries, err := os.ReadDir(packageDir)
+	if err != nil {
+		return "", err
+	}
+
+	latest := ""
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".zip") {
+			continue
+		}
+		version := strings.TrimSuffix(entry.Name(), ".zip")
+		if !versionPattern.MatchString(version) {
+			continue
+		}
+		if _, pre := splitVersion(version); pre != "" {
+			continue
+		}
+		if latest != "" && CompareVersions(version, latest) <= 0 {
+			continue
+		}
+
+		metadata, err := readMetadata(filepath.Join(packageDir, version+".json"))
+		if err != nil || (metadata.Visibility == "private" && !authorized) {
+			continue
+		}
+		latest = version
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no versions found in %s", packageDir)
+	}
+	return latest, nil
+}
+
+// CompareVersions compares two semantic versions and returns -1, 0 or 1.
+// Build metadata is ignored and a pre-release sorts before its release.
+func CompareVersions(a, b string) int {
+	aCore, aPre := splitVersion(a)
+	bCore, bPre := splitVersion(b)
+
+	for i := 0; i < 3; i++ {
+		if aCore[i] != bCore[i] {
+			if aCore[i] < bCore[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	default:
+		return comparePrerelease(aPre, bPre)
+	}
+}
+
+// comparePrerelease compares dot-separated pre-release identifiers as
+// described by semver: numeric identifiers compare numerically and sort
+// before alphanumeric ones, and a shorter list sorts first when all shared
+// identifiers are equal
+func comparePrerelease(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	switch {
+	case len(aParts) < len(bParts):
+		return -1
+	case len(aParts) > len(bParts):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// splitVersion splits a version into its numeric core and pre-release parts
+func splitVersion(version string) ([3]int, string) {
+	var core [3]int
+
+	version = strings.TrimPrefix(version, "v")
+	if i := strings.Index(version, "+"); i >= 0 {
+		version = version[:i]
+	}
+
+	pre := ""
+	if i := strings.Index(version, "-"); i >= 0 {
+		pre = version[i+1:]
+		version = version[:i]
+	}
+
+	for i, part := range strings.SplitN(version, ".", 3) {
+		core[i], _ = strconv.Atoi(part)
+	}
+
+	return core, pre
+}
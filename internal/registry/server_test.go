@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestZip creates a zip archive at path containing the given files
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create zip: %v", err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s to zip: %v", name, err)
+		}
+		fw.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to finish zip: %v", err)
+	}
+}
+
+func TestServerPublishAndDownload(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "secret").Handler())
+	defer server.Close()
+
+	workDir := t.TempDir()
+	zipV1 := filepath.Join(workDir, "v1.zip")
+	zipV2 := filepath.Join(workDir, "v2.zip")
+	writeTestZip(t, zipV1, map[string]string{"rules.json": `{"version":"1.0.0"}`, "style.md": "v1"})
+	writeTestZip(t, zipV2, map[string]string{"rules.json": `{"version":"1.10.0"}`, "style.md": "v2"})
+
+	client := NewClient(server.URL)
+
+	// Publishing without a token is rejected
+	if err := client.PublishRule("acme/style", "1.0.0", zipV1, "public"); err == nil {
+		t.Fatal("Expected publish without login to fail")
+	}
+
+	client.SetAuthToken("wrong")
+	if err := client.PublishRule("acme/style", "1.0.0", zipV1, "public"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("Expected 401 with wrong token, got %v", err)
+	}
+
+	client.SetAuthToken("secret")
+	if err := client.PublishRule("acme/style", "1.0.0", zipV1, "public"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if err := client.PublishRule("acme/style", "1.10.0", zipV2, "public"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	// Republishing an existing version is a conflict
+	err := client.PublishRule("acme/style", "1.0.0", zipV1, "public")
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("Expected version conflict, got %v", err)
+	}
+
+	// Latest resolves to the highest semantic version, not the lexical one
+	anonymous := NewClient(server.URL)
+	rulesDir := t.TempDir()
+	if err := anonymous.DownloadRule("acme", "style", "latest", rulesDir); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(rulesDir, "acme", "style", "style.md"))
+	if err != nil {
+		t.Fatalf("Downloaded rule missing: %v", err)
+	}
+	if string(content) != "v2" {
+		t.Errorf("Expected latest version content 'v2', got %q", content)
+	}
+
+	// Specific versions can still be downloaded
+	rulesDir = t.TempDir()
+	if err := anonymous.DownloadRule("acme", "style", "1.0.0", rulesDir); err != nil {
+		t.Fatalf("Download of 1.0.0 failed: %v", err)
+	}
+	content, _ = os.ReadFile(filepath.Join(rulesDir, "acme", "style", "style.md"))
+	if string(content) != "v1" {
+		t.Errorf("Expected version 1.0.0 content 'v1', got %q", content)
+	}
+
+	// Unknown packages are 404
+	if err := anonymous.DownloadRule("acme", "missing", "latest", t.TempDir()); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected 404 for missing package, got %v", err)
+	}
+}
+
+func TestServerPrivatePackages(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "secret").Handler())
+	defer server.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "pkg.zip")
+	writeTestZip(t, zipPath, map[string]string{"internal.md": "private"})
+
+	client := NewClient(server.URL)
+	client.SetAuthToken("secret")
+	if err := client.PublishRule("acme/internal", "1.0.0", zipPath, "private"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	anonymous := NewClient(server.URL)
+	if err := anonymous.DownloadRule("acme", "internal", "latest", t.TempDir()); err == nil {
+		t.Error("Expected anonymous download of private package to fail")
+	}
+
+	if err := client.DownloadRule("acme", "internal", "latest", t.TempDir()); err != nil {
+		t.Errorf("Authenticated download of private package failed: %v", err)
+	}
+}
+
+func TestServerLatestSkipsPrereleasesAndPrivate(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "secret").Handler())
+	defer server.Close()
+
+	workDir := t.TempDir()
+	publish := func(client *Client, version, visibility string) {
+		t.Helper()
+		zipPath := filepath.Join(workDir, version+".zip")
+		writeTestZip(t, zipPath, map[string]string{"style.md": version})
+		if err := client.PublishRule("acme/style", version, zipPath, visibility); err != nil {
+			t.Fatalf("Publish of %s failed: %v", version, err)
+		}
+	}
+
+	client := NewClient(server.URL)
+	client.SetAuthToken("secret")
+	publish(client, "1.0.0", "public")
+	publish(client, "2.0.0-beta", "public")
+	publish(client, "1.5.0", "private")
+
+	latest := func(client *Client) string {
+		t.Helper()
+		rulesDir := t.TempDir()
+		if err := client.DownloadRule("acme", "style", "latest", rulesDir); err != nil {
+			t.Fatalf("Download failed: %v", err)
+		}
+		content, _ := os.ReadFile(filepath.Join(rulesDir, "acme", "style", "style.md"))
+		return string(content)
+	}
+
+	if got := latest(NewClient(server.URL)); got != "1.0.0" {
+		t.Errorf("Expected anonymous latest to be 1.0.0, got %q", got)
+	}
+	if got := latest(client); got != "1.5.0" {
+		t.Errorf("Expected authorized latest to be 1.5.0, got %q", got)
+	}
+}
+
+func TestServerRejectsBadUploads(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "").Handler())
+	defer server.Close()
+
+	notAZip := filepath.Join(t.TempDir(), "bad.zip")
+	os.WriteFile(notAZip, []byte("not a zip"), 0644)
+
+	client := NewClient(server.URL)
+	client.SetAuthToken("anything")
+	if err := client.PublishRule("acme/bad", "1.0.0", notAZip, "public"); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("Expected 400 for invalid zip, got %v", err)
+	}
+
+	resp, err := http.Get(server.URL + "/v0/acme/..%2f..%2fetc/latest/download")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("Expected path traversal attempt to be rejected")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.10.0", "1.9.0", 1},
+		{"0.9.9", "1.0.0", -1},
+		{"1.0.0-beta", "1.0.0", -1},
+		{"1.0.0", "1.0.0-beta", 1},
+		{"1.0.0-alpha", "1.0.0-beta", -1},
+		{"1.0.0+build", "1.0.0", 0},
+		{"1.0.0-rc.2", "1.0.0-rc.10", -1},
+		{"1.0.0-1", "1.0.0-alpha", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("CompareVersions(%s, %s) = %d, expected %d", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+func TestServerSignedPackages(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "").Handler())
+	defer server.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "pkg.zip")
+	writeTestZip(t, zipPath, map[string]string{"style.md": "signed"})
+
+	client := NewClient(server.URL)
+	client.SetAuthToken("anything")
+
+	// A signature without a fingerprint is rejected
+	err := client.PublishRuleWithMetadata("acme/style", "1.0.0", zipPath, PublishMetadata{Visibility: "public", Signature: "c2ln"})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("Expected 400 for signature without fingerprint, got %v", err)
+	}
+
+	metadata := PublishMetadata{Visibility: "public", Signature: "c2ln", KeyFingerprint: "SHA256:abc"}
+	if err := client.PublishRuleWithMetadata("acme/style", "1.0.0", zipPath, metadata); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	// The signature is handed to VerifyPackage with the archive, before extraction
+	expected, _ := os.ReadFile(zipPath)
+	var gotName, gotSignature, gotFingerprint string
+	var gotArchive []byte
+	client.VerifyPackage = func(name string, archive io.Reader, signature, fingerprint string) error {
+		gotName, gotSignature, gotFingerprint = name, signature, fingerprint
+		gotArchive, _ = io.ReadAll(archive)
+		return nil
+	}
+	if err := client.DownloadRule("acme", "style", "latest", t.TempDir()); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if gotName != "acme/style" || gotSignature != "c2ln" || gotFingerprint != "SHA256:abc" {
+		t.Errorf("Unexpected verify arguments: %s %s %s", gotName, gotSignature, gotFingerprint)
+	}
+	if string(gotArchive) != string(expected) {
+		t.Error("Expected VerifyPackage to receive the published archive")
+	}
+
+	// A failed verification stops the download before anything is written
+	rulesDir := t.TempDir()
+	client.VerifyPackage = func(string, io.Reader, string, string) error {
+		return fmt.Errorf("rejected")
+	}
+	if err := client.DownloadRule("acme", "style", "latest", rulesDir); err == nil || err.Error() != "rejected" {
+		t.Fatalf("Expected verification error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(rulesDir, "acme")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be extracted after failed verification")
+	}
+}
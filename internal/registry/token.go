@@ -0,0 +1,87 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"rules-cli/internal/utils"
+)
+
+// tokenRefreshMargin is how long before it expires a token is refreshed, so
+// it doesn't expire in the middle of a request
+const tokenRefreshMargin = time.Minute
+
+// RefreshFunc obtains a new access token for the client, returning the token
+// and when it expires. It is responsible for saving the refreshed session.
+type RefreshFunc func() (token string, expiresAt time.Time, err error)
+
+// SetTokenExpiry records when the auth token expires, so it can be refreshed
+// before it does
+func (c *Client) SetTokenExpiry(expiresAt time.Time) {
+	c.TokenExpiresAt = expiresAt
+}
+
+// refreshAuthToken replaces the auth token using RefreshAuth
+func (c *Client) refreshAuthToken() error {
+	token, expiresAt, err := c.RefreshAuth()
+	if err != nil {
+		return fmt.Errorf("failed to refresh your session; run 'rules login' again: %w", err)
+	}
+	c.SetAuthToken(token)
+	c.SetTokenExpiry(expiresAt)
+	return nil
+}
+
+// doAuthenticated sends a request to the registry with the auth token, if
+// logged in. The token is refreshed first if it is about to expire, and once
+// more if the registry rejects it, in which case the request is rebuilt with
+// newRequest and sent again.
+func (c *Client) doAuthenticated(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	canRefresh := c.IsLoggedIn && c.RefreshAuth != nil
+	refreshed := false
+
+	if canRefresh && !c.TokenExpiresAt.IsZero() && time.Now().Add(tokenRefreshMargin).After(c.TokenExpiresAt) {
+		if err := c.refreshAuthToken(); err != nil {
+			// A token that hasn't quite expired may still be accepted
+			if time.Now().After(c.TokenExpiresAt) {
+				return nil, err
+			}
+		} else {
+			refreshed = true
+		}
+	}
+
+	resp, err := c.sendWithToken(newRequest)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !canRefresh || refreshed {
+		return resp, err
+	}
+
+	// The token was rejected before its recorded expiry, e.g. it was revoked
+	// or the clock is off. Refresh it and retry once.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if err := c.refreshAuthToken(); err != nil {
+		return nil, err
+	}
+
+	return c.sendWithToken(newRequest)
+}
+
+// sendWithToken builds a request with newRequest and sends it with the
+// current auth token
+func (c *Client) sendWithToken(newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsLoggedIn {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.AuthToken))
+	}
+	utils.SetUserAgent(req)
+
+	client := &http.Client{}
+	return client.Do(req)
+}
@@ -0,0 +1,110 @@
+package registry
+
+import (
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// countingRefresher returns a RefreshFunc that hands out token and counts
+// how often it is called
+func countingRefresher(token string, err error, calls *int) RefreshFunc {
+	return func() (string, time.Time, error) {
+		*calls++
+		if err != nil {
+			return "", time.Time{}, err
+		}
+		return token, time.Now().Add(time.Hour), nil
+	}
+}
+
+func TestClientRefreshesExpiringToken(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "fresh").Handler())
+	defer server.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "pkg.zip")
+	writeTestZip(t, zipPath, map[string]string{"rules.json": `{"version":"1.0.0"}`})
+
+	var calls int
+	client := NewClient(server.URL)
+	client.SetAuthToken("stale")
+	client.SetTokenExpiry(time.Now().Add(30 * time.Second))
+	client.RefreshAuth = countingRefresher("fresh", nil, &calls)
+
+	if err := client.PublishRule("acme/style", "1.0.0", zipPath, "public"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 refresh before expiry, got %d", calls)
+	}
+	if client.AuthToken != "fresh" || client.TokenExpiresAt.Before(time.Now().Add(time.Minute)) {
+		t.Errorf("Expected client to keep the refreshed token, got %q expiring %s", client.AuthToken, client.TokenExpiresAt)
+	}
+
+	// A token that isn't close to expiring is used as is
+	if err := client.PublishRule("acme/style", "1.0.1", zipPath, "public"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no further refresh, got %d refreshes", calls)
+	}
+}
+
+func TestClientRefreshesRejectedToken(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "fresh").Handler())
+	defer server.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "pkg.zip")
+	writeTestZip(t, zipPath, map[string]string{"rules.json": `{"version":"1.0.0"}`})
+
+	// No expiry is known, so the token is only refreshed after a 401, and
+	// the upload is streamed again
+	var calls int
+	client := NewClient(server.URL)
+	client.SetAuthToken("revoked")
+	client.RefreshAuth = countingRefresher("fresh", nil, &calls)
+
+	if err := client.PublishRule("acme/style", "1.0.0", zipPath, "public"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 refresh after 401, got %d", calls)
+	}
+
+	// The request is only retried once
+	calls = 0
+	client.SetAuthToken("revoked")
+	client.RefreshAuth = countingRefresher("also-wrong", nil, &calls)
+	err := client.PublishRule("acme/style", "1.0.1", zipPath, "public")
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected 401 after retry, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected exactly 1 refresh, got %d", calls)
+	}
+}
+
+func TestClientRefreshFailure(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "fresh").Handler())
+	defer server.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "pkg.zip")
+	writeTestZip(t, zipPath, map[string]string{"rules.json": `{"version":"1.0.0"}`})
+
+	var calls int
+	client := NewClient(server.URL)
+	client.SetAuthToken("expired")
+	client.SetTokenExpiry(time.Now().Add(-time.Minute))
+	client.RefreshAuth = countingRefresher("", errors.New("refresh token revoked"), &calls)
+
+	err := client.PublishRule("acme/style", "1.0.0", zipPath, "public")
+	if err == nil || !strings.Contains(err.Error(), "rules login") {
+		t.Errorf("Expected refresh failure asking to log in again, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected 1 refresh attempt, got %d", calls)
+	}
+}
@@ -0,0 +1,132 @@
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ProgressFunc reports how many bytes of a transfer are done. total is -1
+// when the size isn't known. It is called a final time with done == total
+// when the transfer completes.
+type ProgressFunc func(done, total int64)
+
+// progressReader counts the bytes read through it and reports them
+type progressReader struct {
+	reader   io.Reader
+	done     int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.done += int64(n)
+	if n > 0 && p.progress != nil && p.done != p.total {
+		p.progress(p.done, p.total)
+	}
+	return n, err
+}
+
+// finish reports the completed transfer
+func (p *progressReader) finish() {
+	if p.progress != nil {
+		p.progress(p.done, p.done)
+	}
+}
+
+// downloadToTempFile streams a response body into a temporary file and
+// checks that it matches the advertised Content-Length. The caller must
+// release the file with removeTempFile.
+func (c *Client) downloadToTempFile(resp *http.Response) (*os.File, int64, error) {
+	file, err := os.CreateTemp("", "rules-download-*.zip")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	reader := &progressReader{reader: resp.Body, total: resp.ContentLength, progress: c.Progress}
+	size, err := io.Copy(file, reader)
+	if err != nil {
+		removeTempFile(file)
+		if errors.Is(err, io.ErrUnexpectedEOF) && resp.ContentLength >= 0 {
+			return nil, 0, fmt.Errorf("download incomplete: received %d of %d bytes", size, resp.ContentLength)
+		}
+		return nil, 0, fmt.Errorf("failed to download package: %w", err)
+	}
+
+	if resp.ContentLength >= 0 && size != resp.ContentLength {
+		removeTempFile(file)
+		return nil, 0, fmt.Errorf("download size mismatch: received %d bytes, expected %d", size, resp.ContentLength)
+	}
+	reader.finish()
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		removeTempFile(file)
+		return nil, 0, fmt.Errorf("failed to read downloaded package: %w", err)
+	}
+
+	return file, size, nil
+}
+
+// verifyDownload runs VerifyPackage, if set, on a downloaded archive
+func (c *Client) verifyDownload(name string, file *os.File, size int64, signature, fingerprint string) error {
+	if c.VerifyPackage == nil {
+		return nil
+	}
+	return c.VerifyPackage(name, io.NewSectionReader(file, 0, size), signature, fingerprint)
+}
+
+// removeTempFile closes and deletes a temporary file
+func removeTempFile(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
+
+// streamMultipartUpload returns a request body that streams the zip file and
+// metadata as multipart form data through a pipe, along with its content
+// type. The file is read as the request is sent, never held in memory.
+func (c *Client) streamMultipartUpload(zipFilePath string, metadataJSON []byte) (io.ReadCloser, string, error) {
+	file, err := os.Open(zipFilePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open zip file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, "", fmt.Errorf("failed to read zip file: %w", err)
+	}
+
+	pipeReader, pipeWriter := io.Pipe()
+	writer := multipart.NewWriter(pipeWriter)
+
+	go func() {
+		defer file.Close()
+
+		// Closing the pipe with an error makes the request fail with it
+		pipeWriter.CloseWithError(func() error {
+			fileWriter, err := writer.CreateFormFile("file", filepath.Base(zipFilePath))
+			if err != nil {
+				return fmt.Errorf("failed to create form file: %w", err)
+			}
+
+			reader := &progressReader{reader: file, total: info.Size(), progress: c.Progress}
+			if _, err := io.Copy(fileWriter, reader); err != nil {
+				return fmt.Errorf("failed to write zip data to form: %w", err)
+			}
+			reader.finish()
+
+			if err := writer.WriteField("metadata", string(metadataJSON)); err != nil {
+				return fmt.Errorf("failed to write metadata field: %w", err)
+			}
+
+			return writer.Close()
+		}())
+	}()
+
+	return pipeReader, writer.FormDataContentType(), nil
+}
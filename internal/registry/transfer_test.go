@@ -0,0 +1,62 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTransferProgress(t *testing.T) {
+	server := httptest.NewServer(NewServer(t.TempDir(), "").Handler())
+	defer server.Close()
+
+	zipPath := filepath.Join(t.TempDir(), "pkg.zip")
+	writeTestZip(t, zipPath, map[string]string{"style.md": strings.Repeat("rule ", 50000)})
+	info, _ := os.Stat(zipPath)
+
+	var calls int
+	var lastDone, lastTotal int64
+	client := NewClient(server.URL)
+	client.SetAuthToken("anything")
+	client.Progress = func(done, total int64) {
+		calls++
+		lastDone, lastTotal = done, total
+	}
+
+	if err := client.PublishRule("acme/style", "1.0.0", zipPath, "public"); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if calls == 0 || lastDone != info.Size() || lastTotal != info.Size() {
+		t.Errorf("Expected upload progress to finish at %d bytes, got %d/%d after %d calls", info.Size(), lastDone, lastTotal, calls)
+	}
+
+	calls = 0
+	if err := client.DownloadRule("acme", "style", "latest", t.TempDir()); err != nil {
+		t.Fatalf("Download failed: %v", err)
+	}
+	if calls == 0 || lastDone != info.Size() || lastTotal != info.Size() {
+		t.Errorf("Expected download progress to finish at %d bytes, got %d/%d after %d calls", info.Size(), lastDone, lastTotal, calls)
+	}
+}
+
+func TestDownloadChecksContentLength(t *testing.T) {
+	// The server advertises more bytes than it sends
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write([]byte("truncated"))
+	}))
+	defer server.Close()
+
+	rulesDir := t.TempDir()
+	client := NewClient(server.URL)
+	err := client.DownloadRule("acme", "style", "latest", rulesDir)
+	if err == nil || !strings.Contains(err.Error(), "download incomplete") {
+		t.Fatalf("Expected incomplete download error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(rulesDir, "acme")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be extracted from an incomplete download")
+	}
+}
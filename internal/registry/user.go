@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ErrUserInfoUnsupported is returned by GetCurrentUser when the registry
+// doesn't implement the /v0/me endpoint
+var ErrUserInfoUnsupported = errors.New("registry does not provide user information")
+
+// ErrCredentialsRejected is returned when the registry doesn't accept the
+// client's auth token
+var ErrCredentialsRejected = errors.New("the registry rejected your credentials; run 'rules login' again")
+
+// GetCurrentUser fetches the authenticated user's profile, organizations and
+// publish permissions from the registry
+func (c *Client) GetCurrentUser() (*UserInfo, error) {
+	if !c.IsLoggedIn {
+		return nil, fmt.Errorf("you must be logged in to fetch user information")
+	}
+
+	resp, err := c.doAuthenticated(func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", c.BaseURL+"/v0/me", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request user information: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusNotImplemented:
+		return nil, ErrUserInfoUnsupported
+	case http.StatusUnauthorized:
+		return nil, ErrCredentialsRejected
+	default:
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("failed to fetch user information: status %d, response: %s", resp.StatusCode, string(body))
+	}
+
+	var user UserInfo
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, fmt.Errorf("failed to parse user information: %w", err)
+	}
+
+	return &user, nil
+}
+
+// Organizations returns the organizations the user belongs to. An OrgSlug
+// that Orgs doesn't list is included as an organization the user can
+// publish to, leaving the final say to the upload endpoint.
+func (u *UserInfo) Organizations() []OrgInfo {
+	orgs := u.Orgs
+	if u.OrgSlug == "" {
+		return orgs
+	}
+	for _, org := range orgs {
+		if strings.EqualFold(org.Slug, u.OrgSlug) {
+			return orgs
+		}
+	}
+	return append(slices.Clone(orgs), OrgInfo{Slug: u.OrgSlug, CanPublish: true})
+}
+
+// PublishableOwners returns the owner slugs the user may publish packages
+// under: their own username and every organization that grants publishing
+func (u *UserInfo) PublishableOwners() []string {
+	var owners []string
+	if u.Username != "" {
+		owners = append(owners, u.Username)
+	}
+	for _, org := range u.Organizations() {
+		if org.CanPublish {
+			owners = append(owners, org.Slug)
+		}
+	}
+	return owners
+}
+
+// CanPublishTo reports whether the user may publish packages owned by ownerSlug
+func (u *UserInfo) CanPublishTo(ownerSlug string) bool {
+	for _, owner := range u.PublishableOwners() {
+		if strings.EqualFold(owner, ownerSlug) {
+			return true
+		}
+	}
+	return false
+}
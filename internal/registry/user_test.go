@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetCurrentUser(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v0/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(UserInfo{
+			ID:       "user_1",
+			Username: "alice",
+			Orgs: []OrgInfo{
+				{Slug: "acme", Role: "member", CanPublish: true},
+				{Slug: "partner", Role: "viewer", CanPublish: false},
+			},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	if _, err := client.GetCurrentUser(); err == nil {
+		t.Error("Expected an error when not logged in")
+	}
+
+	client.SetAuthToken("wrong")
+	if _, err := client.GetCurrentUser(); err == nil {
+		t.Error("Expected an error for rejected credentials")
+	}
+
+	client.SetAuthToken("secret")
+	user, err := client.GetCurrentUser()
+	if err != nil {
+		t.Fatalf("GetCurrentUser failed: %v", err)
+	}
+
+	tests := []struct {
+		owner    string
+		expected bool
+	}{
+		{"alice", true},
+		{"Alice", true},
+		{"acme", true},
+		{"partner", false},
+		{"someone-else", false},
+	}
+	for _, tt := range tests {
+		if got := user.CanPublishTo(tt.owner); got != tt.expected {
+			t.Errorf("CanPublishTo(%q) = %v, expected %v", tt.owner, got, tt.expected)
+		}
+	}
+}
+
+func TestUserInfoOrgSlug(t *testing.T) {
+	// Registries that only return orgSlug can still be published to
+	user := &UserInfo{Username: "alice", OrgSlug: "acme"}
+	if !user.CanPublishTo("acme") {
+		t.Error("Expected the user to be able to publish to their orgSlug")
+	}
+	if orgs := user.Organizations(); len(orgs) != 1 || orgs[0].Slug != "acme" {
+		t.Errorf("Expected acme among the organizations, got %v", orgs)
+	}
+
+	// Orgs decides when it lists the same organization
+	user.Orgs = []OrgInfo{{Slug: "Acme", Role: "viewer", CanPublish: false}}
+	if user.CanPublishTo("acme") {
+		t.Error("Expected orgs to override orgSlug")
+	}
+	if orgs := user.Organizations(); len(orgs) != 1 {
+		t.Errorf("Expected orgSlug not to be listed twice, got %v", orgs)
+	}
+}
+
+func TestGetCurrentUserUnsupported(t *testing.T) {
+	// The built-in server doesn't implement /v0/me
+	server := httptest.NewServer(NewServer(t.TempDir(), "").Handler())
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.SetAuthToken("anything")
+	if _, err := client.GetCurrentUser(); !errors.Is(err, ErrUserInfoUnsupported) {
+		t.Errorf("Expected ErrUserInfoUnsupported, got %v", err)
+	}
+}
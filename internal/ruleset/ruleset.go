@@ -17,6 +17,11 @@ type RuleSet struct {
 	License     string            `json:"license"`
 	Version     string            `json:"version"`
 	Rules       map[string]string `json:"rules"`
+	// Files lists the files to include when packaging for publish
+	Files []string `json:"files,omitempty"`
+	// Config holds project settings, kept as written since the CLI only
+	// reads them through the config package
+	Config json.RawMessage `json:"config,omitempty"`
 }
 
 // Rule represents a single rule with front matter and content
@@ -0,0 +1,256 @@
+package signing
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy controls what happens when a package can't be verified
+type Policy string
+
+const (
+	// PolicyOff skips signature verification entirely
+	PolicyOff Policy = "off"
+	// PolicyWarn warns about unsigned packages and untrusted keys
+	PolicyWarn Policy = "warn"
+	// PolicyRequire rejects any package not signed by a trusted key
+	PolicyRequire Policy = "require"
+)
+
+// DefaultPolicy is used when no signature policy is configured
+const DefaultPolicy = PolicyWarn
+
+// publicKeyPrefix marks an ed25519 public key in its text form
+const publicKeyPrefix = "ed25519:"
+
+var (
+	// ErrUnsigned is returned when a package has no signature
+	ErrUnsigned = errors.New("package is not signed")
+	// ErrUntrustedKey is returned when a package is signed by a key that
+	// isn't in the trusted keys list
+	ErrUntrustedKey = errors.New("package is signed by an untrusted key")
+	// ErrInvalidSignature is returned when a signature doesn't match the
+	// package contents, which means the package was modified after signing
+	ErrInvalidSignature = errors.New("package signature is invalid")
+)
+
+// ParsePolicy parses a signature policy setting
+func ParsePolicy(value string) (Policy, error) {
+	switch Policy(strings.ToLower(strings.TrimSpace(value))) {
+	case "":
+		return DefaultPolicy, nil
+	case PolicyOff:
+		return PolicyOff, nil
+	case PolicyWarn:
+		return PolicyWarn, nil
+	case PolicyRequire:
+		return PolicyRequire, nil
+	default:
+		return "", fmt.Errorf("invalid signature policy %q: must be one of off, warn or require", value)
+	}
+}
+
+// policyStrictness orders the policies from most to least permissive
+var policyStrictness = map[Policy]int{PolicyOff: 0, PolicyWarn: 1, PolicyRequire: 2}
+
+// Weaker reports whether p lets through packages that other would refuse or
+// warn about
+func (p Policy) Weaker(other Policy) bool {
+	return policyStrictness[p] < policyStrictness[other]
+}
+
+// DefaultKeyPath returns the path of the signing key used when none is given
+func DefaultKeyPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find home directory: %w", err)
+	}
+	return filepath.Join(home, ".rules-cli", "keys", "signing.key"), nil
+}
+
+// PublicKeyPath returns the path of the public key written next to a private key
+func PublicKeyPath(keyPath string) string {
+	return strings.TrimSuffix(keyPath, ".key") + ".pub"
+}
+
+// GenerateKey creates a new ed25519 key pair, writing the private key to
+// keyPath (readable only by the owner) and the public key next to it.
+// An existing key is only replaced if overwrite is set.
+func GenerateKey(keyPath string, overwrite bool) (ed25519.PublicKey, error) {
+	if _, err := os.Stat(keyPath); err == nil && !overwrite {
+		return nil, fmt.Errorf("a signing key already exists at %s", keyPath)
+	}
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	if err := WritePrivateKey(keyPath, privateKey); err != nil {
+		return nil, err
+	}
+
+	publicLine := FormatPublicKey(publicKey) + "\n"
+	if err := os.WriteFile(PublicKeyPath(keyPath), []byte(publicLine), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return publicKey, nil
+}
+
+// WritePrivateKey writes a private key to path as a PKCS #8 PEM block
+func WritePrivateKey(path string, privateKey ed25519.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write private key: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPrivateKey reads a private key written by GenerateKey
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no signing key at %s; create one with 'rules keys generate'", path)
+		}
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("%s is not a PEM encoded private key", path)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse signing key: %w", err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an ed25519 key", path)
+	}
+
+	return privateKey, nil
+}
+
+// FormatPublicKey returns the text form of a public key, as used in the
+// trusted keys list: "ed25519:<base64>"
+func FormatPublicKey(publicKey ed25519.PublicKey) string {
+	return publicKeyPrefix + base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// ParsePublicKey parses a public key in the form returned by FormatPublicKey.
+// Anything after the key, separated by whitespace, is treated as a comment.
+func ParsePublicKey(value string) (ed25519.PublicKey, error) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], publicKeyPrefix) {
+		return nil, fmt.Errorf("public key must start with %q", publicKeyPrefix)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(fields[0], publicKeyPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key encoding: %w", err)
+	}
+	if len(data) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be %d bytes, got %d", ed25519.PublicKeySize, len(data))
+	}
+
+	return ed25519.PublicKey(data), nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a public key
+func Fingerprint(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+}
+
+// digest hashes a package archive for signing. Packages are signed with
+// Ed25519ph over SHA-512 so archives can be streamed instead of held in memory.
+func digest(archive io.Reader) ([]byte, error) {
+	hash := sha512.New()
+	if _, err := io.Copy(hash, archive); err != nil {
+		return nil, fmt.Errorf("failed to read package: %w", err)
+	}
+	return hash.Sum(nil), nil
+}
+
+// Sign signs a package archive and returns the base64 encoded signature
+// and the fingerprint of the signing key
+func Sign(privateKey ed25519.PrivateKey, archive io.Reader) (signature string, fingerprint string, err error) {
+	sum, err := digest(archive)
+	if err != nil {
+		return "", "", err
+	}
+
+	sig, err := privateKey.Sign(nil, sum, &ed25519.Options{Hash: crypto.SHA512})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to sign package: %w", err)
+	}
+
+	publicKey := privateKey.Public().(ed25519.PublicKey)
+	return base64.StdEncoding.EncodeToString(sig), Fingerprint(publicKey), nil
+}
+
+// Verify checks a package archive's signature against the trusted keys.
+// It returns ErrUnsigned, ErrUntrustedKey or ErrInvalidSignature (possibly
+// wrapped) when the package can't be verified.
+func Verify(archive io.Reader, signature, fingerprint string, trustedKeys []string) error {
+	if signature == "" {
+		return ErrUnsigned
+	}
+
+	var publicKey ed25519.PublicKey
+	for _, trusted := range trustedKeys {
+		key, err := ParsePublicKey(trusted)
+		if err != nil {
+			return fmt.Errorf("invalid trusted key %q: %w", trusted, err)
+		}
+		if Fingerprint(key) == fingerprint {
+			publicKey = key
+			break
+		}
+	}
+	if publicKey == nil {
+		return fmt.Errorf("%w %s", ErrUntrustedKey, fingerprint)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	sum, err := digest(archive)
+	if err != nil {
+		return err
+	}
+
+	if err := ed25519.VerifyWithOptions(publicKey, sum, sig, &ed25519.Options{Hash: crypto.SHA512}); err != nil {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
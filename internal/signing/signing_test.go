@@ -0,0 +1,139 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAndLoadKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "keys", "signing.key")
+
+	publicKey, err := GenerateKey(keyPath, false)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatalf("Private key not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected private key mode 0600, got %o", info.Mode().Perm())
+	}
+
+	publicLine, err := os.ReadFile(PublicKeyPath(keyPath))
+	if err != nil {
+		t.Fatalf("Public key not written: %v", err)
+	}
+	if strings.TrimSpace(string(publicLine)) != FormatPublicKey(publicKey) {
+		t.Errorf("Public key file contains %q, expected %q", publicLine, FormatPublicKey(publicKey))
+	}
+
+	privateKey, err := LoadPrivateKey(keyPath)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey failed: %v", err)
+	}
+	if !privateKey.Public().(ed25519.PublicKey).Equal(publicKey) {
+		t.Error("Loaded key doesn't match the generated one")
+	}
+
+	// An existing key is only replaced when asked to
+	if _, err := GenerateKey(keyPath, false); err == nil {
+		t.Error("Expected GenerateKey to refuse to overwrite an existing key")
+	}
+	if _, err := GenerateKey(keyPath, true); err != nil {
+		t.Errorf("GenerateKey with overwrite failed: %v", err)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+	formatted := FormatPublicKey(publicKey)
+
+	parsed, err := ParsePublicKey(formatted + " release key")
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	if !parsed.Equal(publicKey) {
+		t.Error("Parsed key doesn't match")
+	}
+
+	for _, invalid := range []string{"", "rsa:AAAA", "ed25519:not-base64!", "ed25519:AAAA"} {
+		if _, err := ParsePublicKey(invalid); err == nil {
+			t.Errorf("Expected error for %q", invalid)
+		}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+	archive := []byte("package contents")
+
+	signature, fingerprint, err := Sign(privateKey, bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+	if fingerprint != Fingerprint(publicKey) {
+		t.Errorf("Expected fingerprint %s, got %s", Fingerprint(publicKey), fingerprint)
+	}
+
+	trusted := []string{FormatPublicKey(otherKey), FormatPublicKey(publicKey) + " publisher"}
+
+	tests := []struct {
+		name        string
+		archive     []byte
+		signature   string
+		trustedKeys []string
+		expectErr   error
+	}{
+		{"trusted key", archive, signature, trusted, nil},
+		{"unsigned", archive, "", trusted, ErrUnsigned},
+		{"untrusted key", archive, signature, []string{FormatPublicKey(otherKey)}, ErrUntrustedKey},
+		{"no trusted keys", archive, signature, nil, ErrUntrustedKey},
+		{"modified archive", []byte("tampered contents"), signature, trusted, ErrInvalidSignature},
+		{"garbage signature", archive, "!!!", trusted, ErrInvalidSignature},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(bytes.NewReader(tt.archive), tt.signature, fingerprint, tt.trustedKeys)
+			if tt.expectErr == nil {
+				if err != nil {
+					t.Errorf("Expected verification to pass, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("Expected %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := map[string]Policy{
+		"":        DefaultPolicy,
+		"off":     PolicyOff,
+		"warn":    PolicyWarn,
+		"Require": PolicyRequire,
+	}
+	for value, expected := range tests {
+		policy, err := ParsePolicy(value)
+		if err != nil {
+			t.Errorf("ParsePolicy(%q) failed: %v", value, err)
+		}
+		if policy != expected {
+			t.Errorf("ParsePolicy(%q) = %s, expected %s", value, policy, expected)
+		}
+	}
+
+	if _, err := ParsePolicy("strict"); err == nil {
+		t.Error("Expected error for unknown policy")
+	}
+}
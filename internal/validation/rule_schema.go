@@ -63,6 +63,11 @@ func ValidateRuleFile(filePath string) (*ValidationResult, error) {
 		return nil, fmt.Errorf("failed to read file %s: %w", filePath, err)
 	}
 
+	return ValidateRuleContent(content)
+}
+
+// ValidateRuleContent validates the frontmatter of a markdown rule's content
+func ValidateRuleContent(content []byte) (*ValidationResult, error) {
 	// Parse frontmatter
 	frontmatter, err := extractFrontmatter(content)
 	if err != nil {
@@ -287,3 +287,41 @@ func generateTags(n int) string {
 	}
 	return result
 }
+
+func TestValidateRuleContent(t *testing.T) {
+	result, err := ValidateRuleContent([]byte("---\nalwaysApply: true\n---\n\n# Rule\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Valid {
+		t.Errorf("Expected valid content, got errors: %v", result.Errors)
+	}
+
+	result, err = ValidateRuleContent([]byte("---\nalwaysApply: sometimes\ncolour: red\n---\n\n# Rule\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Valid {
+		t.Fatal("Expected invalid content")
+	}
+
+	fields := make(map[string]bool)
+	for _, validationErr := range result.Errors {
+		fields[validationErr.Field] = true
+		if validationErr.Field == "root" {
+			fields[validationErr.SchemaPath] = true
+		}
+	}
+	if !fields["alwaysApply"] || !fields["colour"] {
+		t.Errorf("Expected errors for alwaysApply and colour, got %v", result.Errors)
+	}
+
+	// Unparseable frontmatter is reported as a validation error
+	result, err = ValidateRuleContent([]byte("---\ndescription: \"Test\"\n\nno closing delimiter\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Valid || len(result.Errors) != 1 || result.Errors[0].Field != "frontmatter" {
+		t.Errorf("Expected a single frontmatter error, got %v", result.Errors)
+	}
+}
@@ -18,59 +18,20 @@ if [ ! -f "$CONFIG_FILE" ]; then
   exit 1
 fi
 
-# Create a temporary directory
-TEMP_DIR=$(mktemp -d)
-echo -e "${GREEN}Created temporary directory:${NC} $TEMP_DIR"
-
-# Clean up temp directory on exit
-cleanup() {
-  echo -e "${GREEN}Cleaning up temporary directory...${NC}"
-  rm -rf "$TEMP_DIR"
-}
-trap cleanup EXIT
-
-# Build the latest version of the CLI
-echo -e "${GREEN}Building the CLI...${NC}"
-# Get version from package.json
-VERSION=$(node -p "require('./package.json').version")
-go build -ldflags="-X main.Version=${VERSION} -X rules-cli/internal/utils.Version=${VERSION}" -o ./rules-cli
-
-# Copy the CLI to the temporary directory
-cp ./rules-cli "$TEMP_DIR/"
-cd "$TEMP_DIR"
-
-echo -e "${GREEN}Working in temporary directory:${NC} $TEMP_DIR"
-
-# Read commands from the config file
+# Make sure every configured golden file exists so the test picks it up
 while IFS= read -r line || [ -n "$line" ]; do
   # Skip comments and empty lines
   [[ "$line" =~ ^#.*$ || -z "$line" ]] && continue
-  
-  # Split the line into command and output file
-  cmd=$(echo "$line" | cut -d'|' -f1)
+
   output_file=$(echo "$line" | cut -d'|' -f2)
-  
-  # Convert output file path to absolute path based on original working directory
-  abs_output_file="$(cd - > /dev/null && pwd)/$output_file"
-  
-  # Ensure the directory exists
-  mkdir -p "$(dirname "$abs_output_file")"
-  
-  echo -e "${GREEN}Generating golden file for:${NC} $cmd"
-  echo -e "${GREEN}Output file:${NC} $abs_output_file"
-  
-  # Execute the command and capture output (including stderr)
-  if [ -z "$cmd" ]; then
-    # If command is empty, just run the CLI without arguments
-    ./rules-cli > "$abs_output_file" 2>&1 || true
-  else
-    # Otherwise run with the specified arguments
-    ./rules-cli $cmd > "$abs_output_file" 2>&1 || true
-  fi
-done < "$(cd - > /dev/null && pwd)/$CONFIG_FILE"
+  mkdir -p "$(dirname "$output_file")"
+  touch "$output_file"
+done < "$CONFIG_FILE"
 
-# Return to original directory
-cd - > /dev/null
+# The golden test runs every command against fake registry and GitHub
+# servers with an isolated home directory, and -update writes the output
+echo -e "${GREEN}Running golden tests in update mode...${NC}"
+(cd tests && go test -run TestGoldenFiles -update .)
 
 echo -e "${YELLOW}Golden files generated successfully!${NC}"
-echo -e "${YELLOW}Review changes before committing.${NC}"
\ No newline at end of file
+echo -e "${YELLOW}Review changes before committing.${NC}"
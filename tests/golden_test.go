@@ -2,12 +2,17 @@ package tests
 
 import (
 	"bufio"
+	"crypto/ed25519"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+
+	"rules-cli/internal/signing"
 )
 
 // CommandConfig represents a mapping between a command and its golden file
@@ -117,6 +122,262 @@ func matchWithPlaceholders(actual, expected string) bool {
 	return false
 }
 
+// update rewrites golden files with the actual output instead of comparing
+var update = flag.Bool("update", false, "update golden files with actual command output")
+
+// cliPath is the CLI binary shared by all tests in this package
+var cliPath string
+
+// TestMain builds the CLI once into a temporary directory
+func TestMain(m *testing.M) {
+	flag.Parse()
+
+	binDir, err := os.MkdirTemp("", "rules-cli-bin-*")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create temporary directory: %v\n", err)
+		os.Exit(1)
+	}
+	cliPath = filepath.Join(binDir, "rules-cli")
+
+	// Get version from package.json
+	version := "dev"
+	versionCmd := exec.Command("node", "-p", "require('../package.json').version")
+	if versionBytes, err := versionCmd.Output(); err == nil {
+		version = strings.TrimSpace(string(versionBytes))
+	}
+
+	buildCmd := exec.Command("go", "build", "-ldflags", fmt.Sprintf("-X main.Version=%s -X rules-cli/internal/utils.Version=%s", version, version), "-o", cliPath, "..")
+	if output, err := buildCmd.CombinedOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to build CLI: %v\n%s", err, output)
+		os.RemoveAll(binDir)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(binDir)
+	os.Exit(code)
+}
+
+// hermeticEnv returns an environment for running the CLI that never touches
+// the real home directory, credentials or network services
+func hermeticEnv(t *testing.T, home, registryURL, githubURL string) []string {
+	t.Helper()
+
+	// Stub out browser launchers so login doesn't open anything
+	stubDir := filepath.Join(home, "bin")
+	if err := os.MkdirAll(stubDir, 0755); err != nil {
+		t.Fatalf("Failed to create stub directory: %v", err)
+	}
+	for _, name := range []string{"xdg-open", "open"} {
+		os.WriteFile(filepath.Join(stubDir, name), []byte("#!/bin/sh\nexit 0\n"), 0755)
+	}
+
+	var env []string
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "RULES_") || strings.HasPrefix(kv, "CONTINUE_API_KEY=") ||
+			strings.HasPrefix(kv, "HOME=") || strings.HasPrefix(kv, "PATH=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	return append(env,
+		"HOME="+home,
+		"USERPROFILE="+home,
+		"PATH="+stubDir+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"RULES_REGISTRY_URL="+registryURL,
+		"RULES_API_BASE="+registryURL,
+		"RULES_GITHUB_API_URL="+githubURL,
+		"NO_COLOR=1",
+	)
+}
+
+// writePackage creates a publishable rules package in dir
+func writePackage(t *testing.T, dir, name, version string) {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("Failed to create package directory: %v", err)
+	}
+	rulesJSON := fmt.Sprintf(`{"name": %q, "version": %q, "rules": {}}`, name, version)
+	os.WriteFile(filepath.Join(dir, "rules.json"), []byte(rulesJSON), 0644)
+	os.WriteFile(filepath.Join(dir, "style.md"), []byte("---\nalwaysApply: true\n---\n\n# Style\n"), 0644)
+}
+
+// writeProjectConfig writes the project config file, .rules/config.yaml, in dir
+func writeProjectConfig(t *testing.T, dir, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(dir, ".rules"), 0755); err != nil {
+		t.Fatalf("Failed to create .rules directory: %v", err)
+	}
+	os.WriteFile(filepath.Join(dir, ".rules", "config.yaml"), []byte(content), 0644)
+}
+
+// envValue returns the value of a variable in an environment list
+func envValue(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			value = strings.TrimPrefix(kv, key+"=")
+		}
+	}
+	return value
+}
+
+// prepareGolden sets up the working directory for a golden command and
+// returns the stdin to feed it and any extra environment variables
+func prepareGolden(t *testing.T, goldenFile, cmd, workDir string, env []string) (string, []string) {
+	t.Helper()
+
+	runWithInput := func(stdin string, args ...string) {
+		c := exec.Command(cliPath, args...)
+		c.Dir = workDir
+		c.Env = env
+		c.Stdin = strings.NewReader(stdin)
+		if output, err := c.CombinedOutput(); err != nil {
+			t.Logf("Setup command %v failed (this might be expected): %v\n%s", args, err, output)
+		}
+	}
+	run := func(args ...string) {
+		runWithInput("", args...)
+	}
+
+	switch {
+	case goldenFile == "golden/publish/publish.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "starter/test-rules", "1.0.0")
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/publish/conflict.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "starter/nextjs-rules", "1.0.0")
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/publish/dry_run.golden", goldenFile == "golden/pack/pack.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "starter/test-rules", "1.0.0")
+		return "", nil
+	case goldenFile == "golden/publish/dry_run_excluded.golden":
+		pkgDir := filepath.Join(workDir, "pkg")
+		writePackage(t, pkgDir, "starter/test-rules", "1.0.0")
+		os.WriteFile(filepath.Join(pkgDir, "index.js"), []byte("module.exports = {}\n"), 0644)
+		os.WriteFile(filepath.Join(pkgDir, "draft.md"), []byte("# Draft\n"), 0644)
+		os.WriteFile(filepath.Join(pkgDir, ".env"), []byte("TOKEN=secret\n"), 0644)
+		os.WriteFile(filepath.Join(pkgDir, ".rulesignore"), []byte("draft.md\n"), 0644)
+		os.MkdirAll(filepath.Join(pkgDir, "node_modules", "dep"), 0755)
+		os.WriteFile(filepath.Join(pkgDir, "node_modules", "dep", "README.md"), []byte("# Dep\n"), 0644)
+		return "", nil
+	case goldenFile == "golden/publish/file.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "starter/test-rules", "1.0.0")
+		run("pack", "pkg")
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/publish/invalid_rules.golden", goldenFile == "golden/publish/force.golden":
+		pkgDir := filepath.Join(workDir, "pkg")
+		writePackage(t, pkgDir, "starter/test-rules", "1.0.0")
+		os.WriteFile(filepath.Join(pkgDir, "broken.md"), []byte("---\nalwaysApply: sometimes\ncolour: red\n---\n\n# Broken\n"), 0644)
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/publish/forbidden.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "readonly/test-rules", "1.0.0")
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/whoami/authenticated.golden":
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/publish/invalid.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "no-owner", "1.0.0")
+		return "", nil
+	case goldenFile == "golden/publish/signed.golden":
+		writePackage(t, filepath.Join(workDir, "pkg"), "starter/test-rules", "1.0.0")
+		keyPath := filepath.Join(envValue(env, "HOME"), ".rules-cli", "keys", "signing.key")
+		if err := signing.WritePrivateKey(keyPath, fakeSigningKey); err != nil {
+			t.Fatalf("Failed to write signing key: %v", err)
+		}
+		return "", []string{"CONTINUE_API_KEY=" + fakeToken}
+	case goldenFile == "golden/add/signed.golden", goldenFile == "golden/add/unsigned_required.golden",
+		goldenFile == "golden/add/tampered.golden":
+		// A user config that trusts the fake key. Tampering must be caught
+		// even under the default warn policy, so only require it elsewhere.
+		globalDir := filepath.Join(envValue(env, "HOME"), ".rules-cli")
+		os.MkdirAll(globalDir, 0755)
+		globalConfig := fmt.Sprintf("trusted_keys:\n  - %s\n", signing.FormatPublicKey(fakeSigningKey.Public().(ed25519.PublicKey)))
+		os.WriteFile(filepath.Join(globalDir, "rules-cli.yaml"), []byte(globalConfig), 0644)
+		if goldenFile != "golden/add/tampered.golden" {
+			writeProjectConfig(t, workDir, "signature_policy: require\n")
+		}
+		run("init")
+	case goldenFile == "golden/add/project_trusted_keys.golden", goldenFile == "golden/add/project_trusted_keys_opt_in.golden":
+		// Keys trusted by the project config are only accepted once the
+		// user opts in
+		if goldenFile == "golden/add/project_trusted_keys_opt_in.golden" {
+			globalDir := filepath.Join(envValue(env, "HOME"), ".rules-cli")
+			os.MkdirAll(globalDir, 0755)
+			os.WriteFile(filepath.Join(globalDir, "rules-cli.yaml"), []byte("trust_project_keys: true\n"), 0644)
+		}
+		projectConfig := fmt.Sprintf("signature_policy: require\ntrusted_keys:\n  - %s\n", signing.FormatPublicKey(fakeSigningKey.Public().(ed25519.PublicKey)))
+		writeProjectConfig(t, workDir, projectConfig)
+		run("init")
+	case goldenFile == "golden/add/render_targets.golden", goldenFile == "golden/add/no_render.golden":
+		writeProjectConfig(t, workDir, "targets: [cursor, claude]\n")
+		run("init")
+	case goldenFile == "golden/import/split.golden":
+		os.WriteFile(filepath.Join(workDir, "CLAUDE.md"), []byte("# Rules\n\n## Style\n\nUse gofmt.\n\n## Testing\n\nRun go test.\n"), 0644)
+	case goldenFile == "golden/import/split_file.golden":
+		os.WriteFile(filepath.Join(workDir, ".cursorrules"), []byte("Be concise.\n\n## Code Style\n\n# Code style\n\nUse gofmt.\n"), 0644)
+	case strings.HasPrefix(goldenFile, "golden/import/"):
+		rulesDir := filepath.Join(workDir, ".windsurf", "rules")
+		os.MkdirAll(rulesDir, 0755)
+		os.WriteFile(filepath.Join(rulesDir, "style.md"), []byte("---\ntrigger: always_on\n---\n\n# Style\n"), 0644)
+		os.WriteFile(filepath.Join(rulesDir, "review.md"), []byte("---\ntrigger: model_decision\ndescription: Code review checklist\n---\n\n# Review\n"), 0644)
+		if goldenFile == "golden/import/existing.golden" {
+			os.MkdirAll(filepath.Join(workDir, ".rules"), 0755)
+			os.WriteFile(filepath.Join(workDir, ".rules", "style.md"), []byte("# Style\n"), 0644)
+		}
+	case goldenFile == "golden/render/stale.golden":
+		os.MkdirAll(filepath.Join(workDir, ".rules"), 0755)
+		os.WriteFile(filepath.Join(workDir, ".rules", "style.md"), []byte("# Style\n"), 0644)
+		run("render", "cursor")
+		os.Rename(filepath.Join(workDir, ".rules", "style.md"), filepath.Join(workDir, ".rules", "code-style.md"))
+		os.WriteFile(filepath.Join(workDir, ".cursor", "rules", "local.mdc"), []byte("# Local\n"), 0644)
+	case goldenFile == "golden/render/watch_check.golden":
+		os.MkdirAll(filepath.Join(workDir, ".rules"), 0755)
+	case goldenFile == "golden/render/check.golden", goldenFile == "golden/render/check_clean.golden", goldenFile == "golden/render/diff.golden":
+		os.MkdirAll(filepath.Join(workDir, ".rules"), 0755)
+		os.WriteFile(filepath.Join(workDir, ".rules", "style.md"), []byte("# Style\n\nUse gofmt.\n"), 0644)
+		os.WriteFile(filepath.Join(workDir, ".rules", "review.md"), []byte("# Review\n"), 0644)
+		run("render", "cursor")
+		if goldenFile != "golden/render/check_clean.golden" {
+			os.WriteFile(filepath.Join(workDir, ".rules", "style.md"), []byte("# Style\n\nUse gofmt and go vet.\n"), 0644)
+			os.WriteFile(filepath.Join(workDir, ".rules", "testing.md"), []byte("# Testing\n"), 0644)
+			os.Remove(filepath.Join(workDir, ".rules", "review.md"))
+		}
+	case goldenFile == "golden/render/targets.golden":
+		writeProjectConfig(t, workDir, "targets: [cursor, claude]\n")
+		os.WriteFile(filepath.Join(workDir, ".rules", "style.md"), []byte("---\nalwaysApply: true\n---\n\n# Style\n"), 0644)
+	case strings.HasPrefix(cmd, "login --paste"), goldenFile == "golden/login/with_token.golden":
+		return fakeToken + "\n", nil
+	case goldenFile == "golden/login/with_token_invalid.golden":
+		return "not-a-token\n", nil
+	case goldenFile == "golden/login/scoped_empty.golden":
+		writeProjectConfig(t, workDir, "registries:\n  \"@acme\": https://rules.acme.dev\n")
+		return "\n", nil
+	case goldenFile == "golden/auth/status.golden", goldenFile == "golden/auth/status_json.golden":
+		runWithInput(fakeToken+"\n", "login", "--with-token")
+	case goldenFile == "golden/auth/status_logged_out.golden":
+		return "", nil
+	case goldenFile == "golden/config/list_project.golden":
+		writeProjectConfig(t, workDir, "# Pinned for this project\nsignature_policy: require\nregistries:\n  \"@acme\": https://rules.acme.dev\n")
+	case goldenFile == "golden/config/get_project_ignored.golden", goldenFile == "golden/config/list_project_ignored.golden":
+		writeProjectConfig(t, workDir, "api_base: https://rules.example.com\nsignature_policy: off\ntargets: [cursor]\n")
+	case strings.HasPrefix(cmd, "auth "):
+		// Log in to two profiles
+		runWithInput(fakeToken+"\n", "login", "--paste")
+		runWithInput(fakeToken+"\n", "login", "--paste", "--profile", "work")
+	case strings.HasPrefix(cmd, "add "):
+		// For add commands, run init first to create rules.json
+		run("init")
+	case cmd == "install", strings.HasPrefix(cmd, "remove "):
+		// Set up rules.json with a rule installed
+		run("init")
+		run("add", "starter/nextjs-rules")
+	}
+
+	return "", nil
+}
+
 func TestGoldenFiles(t *testing.T) {
 	// Read command configurations
 	goldenToCommand, err := readCommandConfigs()
@@ -138,24 +399,6 @@ func TestGoldenFiles(t *testing.T) {
 		t.Fatal("No golden files found. Run scripts/generate_golden.sh first.")
 	}
 
-	// Make sure the CLI binary exists
-	cliPath := "../rules-cli"
-	if _, err := os.Stat(cliPath); os.IsNotExist(err) {
-		// Build the CLI if it doesn't exist
-		// Get version from package.json
-		versionCmd := exec.Command("node", "-p", "require('../package.json').version")
-		versionBytes, err := versionCmd.Output()
-		if err != nil {
-			t.Fatalf("Failed to get version from package.json: %v", err)
-		}
-		version := strings.TrimSpace(string(versionBytes))
-
-		cmd := exec.Command("go", "build", "-ldflags", fmt.Sprintf("-X main.Version=%s -X rules-cli/internal/utils.Version=%s", version, version), "-o", cliPath, "../main.go")
-		if err := cmd.Run(); err != nil {
-			t.Fatalf("Failed to build CLI: %v", err)
-		}
-	}
-
 	for _, goldenFile := range goldenFiles {
 		t.Run(goldenFile, func(t *testing.T) {
 			// Look up the command for this golden file
@@ -165,58 +408,16 @@ func TestGoldenFiles(t *testing.T) {
 				return
 			}
 
-			// Create a temporary directory for this test
-			tempDir, err := os.MkdirTemp("", "rules-cli-test-*")
-			if err != nil {
-				t.Fatalf("Failed to create temporary directory: %v", err)
-			}
-			defer os.RemoveAll(tempDir)
-
-			// Copy the CLI binary to the temporary directory
-			tempCliPath := filepath.Join(tempDir, "rules-cli")
-			if err := copyFile(cliPath, tempCliPath); err != nil {
-				t.Fatalf("Failed to copy CLI binary: %v", err)
-			}
-
-			// Change to the temporary directory
-			originalDir, err := os.Getwd()
-			if err != nil {
-				t.Fatalf("Failed to get current directory: %v", err)
-			}
-			defer os.Chdir(originalDir)
-
-			if err := os.Chdir(tempDir); err != nil {
-				t.Fatalf("Failed to change to temporary directory: %v", err)
-			}
+			// Every command gets its own working directory, home directory
+			// and fake services so tests can't leak state into each other
+			workDir := t.TempDir()
+			home := t.TempDir()
+			fakeRegistry := newFakeRegistry(t)
+			fakeGitHub := newFakeGitHub(t)
+			env := hermeticEnv(t, home, fakeRegistry.URL, fakeGitHub.URL)
 
 			// Run prerequisite commands based on the current command
-			if strings.HasPrefix(cmd, "add ") {
-				// For add commands, run init first to create rules.json
-				initCmd := exec.Command(tempCliPath, "init")
-				if err := initCmd.Run(); err != nil {
-					t.Logf("Init command failed (this might be expected): %v", err)
-				}
-			} else if cmd == "install" {
-				// For install command, run init and add to set up rules.json with rules
-				initCmd := exec.Command(tempCliPath, "init")
-				if err := initCmd.Run(); err != nil {
-					t.Logf("Init command failed (this might be expected): %v", err)
-				}
-				addCmd := exec.Command(tempCliPath, "add", "starter/nextjs-rules")
-				if err := addCmd.Run(); err != nil {
-					t.Logf("Add command failed (this might be expected): %v", err)
-				}
-			} else if strings.HasPrefix(cmd, "remove ") {
-				// For remove commands, run init and add to set up rules.json with rules
-				initCmd := exec.Command(tempCliPath, "init")
-				if err := initCmd.Run(); err != nil {
-					t.Logf("Init command failed (this might be expected): %v", err)
-				}
-				addCmd := exec.Command(tempCliPath, "add", "starter/nextjs-rules")
-				if err := addCmd.Run(); err != nil {
-					t.Logf("Add command failed (this might be expected): %v", err)
-				}
-			}
+			stdin, extraEnv := prepareGolden(t, goldenFile, cmd, workDir, env)
 
 			// Split the command into arguments
 			var args []string
@@ -225,25 +426,42 @@ func TestGoldenFiles(t *testing.T) {
 			}
 
 			// Run the command
-			execCmd := exec.Command(tempCliPath, args...)
-			output, err := execCmd.CombinedOutput()
+			execCmd := exec.Command(cliPath, args...)
+			execCmd.Dir = workDir
+			execCmd.Env = append(env, extraEnv...)
+			execCmd.Stdin = strings.NewReader(stdin)
+			output, _ := execCmd.CombinedOutput()
 
 			// We don't fail the test if the command returns non-zero
 			// because some golden files might be testing error cases
 
+			// Fake servers listen on random ports, so hide their URLs
+			actual := strings.ReplaceAll(string(output), fakeRegistry.URL, "<REGISTRY_URL>")
+			actual = strings.ReplaceAll(actual, fakeGitHub.URL, "<GITHUB_URL>")
+
 			// Read expected output
-			expectedBytes, err := os.ReadFile(filepath.Join(originalDir, goldenFile))
-			if err != nil {
+			expectedBytes, err := os.ReadFile(goldenFile)
+			if err != nil && !*update {
 				t.Fatalf("Failed to read golden file: %v", err)
 			}
 
 			expected := string(expectedBytes)
-			actual := string(output)
 
 			// Normalize line endings for cross-platform compatibility
 			expected = strings.ReplaceAll(expected, "\r\n", "\n")
 			actual = strings.ReplaceAll(actual, "\r\n", "\n")
 
+			if *update {
+				if strings.Contains(expected, "<VERSION_PLACEHOLDER>") {
+					actual = versionPlaceholderNormalize(actual)
+				}
+				actual = statePattern.ReplaceAllString(actual, "state=<STATE_PLACEHOLDER>")
+				if err := os.WriteFile(goldenFile, []byte(actual), 0644); err != nil {
+					t.Fatalf("Failed to update golden file: %v", err)
+				}
+				return
+			}
+
 			// Special case for login test which has placeholders
 			if strings.Contains(expected, "<STATE_PLACEHOLDER>") {
 				if !matchWithPlaceholders(actual, expected) {
@@ -271,6 +489,9 @@ func TestGoldenFiles(t *testing.T) {
 	}
 }
 
+// statePattern matches the OAuth state parameter in the login URL
+var statePattern = regexp.MustCompile(`state=[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
+
 // versionPlaceholderNormalize replaces the version number in the version output with the placeholder
 func versionPlaceholderNormalize(s string) string {
 	lines := strings.Split(s, "\n")
@@ -295,35 +516,12 @@ func versionPlaceholderNormalize(s string) string {
 	return strings.Join(lines, "\n")
 }
 
-// copyFile copies a file from src to dst
-func copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
-	}
-	defer destFile.Close()
-
-	_, err = destFile.ReadFrom(sourceFile)
-	if err != nil {
-		return err
-	}
-
-	// Make the copied file executable
-	return os.Chmod(dst, 0755)
-}
-
 // TestHelp provides a simple example to ensure our testing framework works
 func TestHelp(t *testing.T) {
 	// This test just makes sure the CLI can run and produce some output
 	// It's useful for initial verification of the test setup
 
-	cmd := exec.Command("../rules-cli", "--help")
+	cmd := exec.Command(cliPath, "--help")
 	output, err := cmd.CombinedOutput()
 
 	if err != nil {